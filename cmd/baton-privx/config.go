@@ -29,6 +29,19 @@ var (
 		"oauth-client-secret",
 		field.WithDescription("The OAuth Client Secret (a base64 string.)"),
 	)
+	requestTimeoutField = field.IntField(
+		"request-timeout",
+		field.WithDescription("Deadline in seconds for each PrivX API call, 0 to disable"),
+		field.WithDefaultValue(60),
+	)
+	syncSessionsField = field.BoolField(
+		"sync-sessions",
+		field.WithDescription("Sync the live PrivX web and API sessions of users"),
+	)
+	terminateSessionsField = field.BoolField(
+		"terminate-sessions",
+		field.WithDescription("Terminate a user's PrivX sessions when a role is revoked from them. Deprovisioned users' sessions are always terminated"),
+	)
 )
 
 // configurationFields defines the external configuration required for the connector to run.
@@ -38,6 +51,9 @@ var configurationFields = []field.SchemaField{
 	baseUrlField,
 	oauthClientIdField,
 	oauthClientSecretField,
+	requestTimeoutField,
+	syncSessionsField,
+	terminateSessionsField,
 }
 
 var configuration = field.NewConfiguration(configurationFields)
@@ -59,5 +75,8 @@ func validateConfig(ctx context.Context, v *viper.Viper) error {
 	if v.GetString(oauthClientSecretField.FieldName) == "" {
 		return fmt.Errorf("oauth-client-secret is required")
 	}
+	if v.GetInt(requestTimeoutField.FieldName) < 0 {
+		return fmt.Errorf("request-timeout must not be negative")
+	}
 	return nil
 }
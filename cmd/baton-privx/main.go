@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	configschema "github.com/conductorone/baton-sdk/pkg/config"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
@@ -53,6 +54,9 @@ func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, e
 		v.GetString(apiClientSecretField.FieldName),
 		v.GetString(oauthClientIdField.FieldName),
 		v.GetString(oauthClientSecretField.FieldName),
+		time.Duration(v.GetInt(requestTimeoutField.FieldName))*time.Second,
+		v.GetBool(syncSessionsField.FieldName),
+		v.GetBool(terminateSessionsField.FieldName),
 	)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
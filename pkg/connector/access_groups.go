@@ -0,0 +1,243 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type accessGroupBuilder struct {
+	client client.PrivXClient
+	// roleIdsByAccessGroup are the IDs of the roles in each access group,
+	// read by the first Grants call of each sync and reused for every access
+	// group.
+	roleIdsByAccessGroup map[string][]string
+}
+
+func (o *accessGroupBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return accessGroupResourceType
+}
+
+// List returns all the access groups from the authorizer as resource objects.
+func (o *accessGroupBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	offset, limit, err := parsePageToken(pToken)
+	if err != nil {
+		logger.Error("invalid page token", zap.Error(err))
+	}
+
+	privXAccessGroups, nextToken, rateLimit, err := o.client.GetAccessGroups(ctx, offset, limit)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching access groups", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	if pToken.Token == "" {
+		o.roleIdsByAccessGroup = nil
+	}
+
+	accessGroupResources := make([]*v2.Resource, 0)
+	for _, accessGroup := range privXAccessGroups {
+		accessGroupCopy := accessGroup
+		newResource, err := accessGroupResource(ctx, &accessGroupCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		accessGroupResources = append(accessGroupResources, newResource)
+	}
+
+	return accessGroupResources, nextToken, outputAnnotations, nil
+}
+
+// Entitlements returns the `member` entitlement of an access group, granted
+// to the roles that belong to it.
+func (o *accessGroupBuilder) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlements := []*v2.Entitlement{
+		entitlement.NewAssignmentEntitlement(
+			resource,
+			EntitlementMember,
+			entitlement.WithGrantableTo(roleResourceType),
+			entitlement.WithDescription(fmt.Sprintf("Role belongs to the %s access group", resource.DisplayName)),
+			entitlement.WithDisplayName(fmt.Sprintf("%s access group %s", resource.DisplayName, EntitlementMember)),
+		),
+	}
+	return entitlements, "", nil, nil
+}
+
+// Grants grants membership of the access group to each role that belongs to
+// it.
+func (o *accessGroupBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	roleIdsByAccessGroup, rateLimit, err := o.getRoleIdsByAccessGroup(ctx)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", outputAnnotations, err
+	}
+
+	var memberGrants []*v2.Grant
+	for _, roleId := range roleIdsByAccessGroup[resource.Id.Resource] {
+		memberGrants = append(
+			memberGrants,
+			grant.NewGrant(
+				resource,
+				EntitlementMember,
+				&v2.ResourceId{
+					ResourceType: roleResourceType.Id,
+					Resource:     roleId,
+				},
+			),
+		)
+	}
+
+	return memberGrants, "", outputAnnotations, nil
+}
+
+// getRoleIdsByAccessGroup pages through every role on the first call of a
+// sync and groups their IDs by access group.
+func (o *accessGroupBuilder) getRoleIdsByAccessGroup(
+	ctx context.Context,
+) (map[string][]string, *v2.RateLimitDescription, error) {
+	if o.roleIdsByAccessGroup != nil {
+		return o.roleIdsByAccessGroup, nil, nil
+	}
+
+	privXRoles, rateLimit, err := o.client.GetAllRoles(ctx)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	roleIdsByAccessGroup := make(map[string][]string)
+	for _, role := range privXRoles {
+		roleIdsByAccessGroup[role.AccessGroupID] = append(roleIdsByAccessGroup[role.AccessGroupID], role.ID)
+	}
+	o.roleIdsByAccessGroup = roleIdsByAccessGroup
+
+	return roleIdsByAccessGroup, rateLimit, nil
+}
+
+// Grant moves a role into the access group. A role belongs to exactly one
+// access group, so this also revokes its previous membership.
+func (o *accessGroupBuilder) Grant(
+	ctx context.Context,
+	principal *v2.Resource,
+	entitlement *v2.Entitlement,
+) (annotations.Annotations, error) {
+	if principal.Id.ResourceType != roleResourceType.Id {
+		return nil, status.Error(codes.InvalidArgument, "baton-privx: only roles can be moved to access groups")
+	}
+
+	err := o.client.SetRoleAccessGroup(
+		ctx,
+		principal.Id.Resource,
+		entitlement.Resource.Id.Resource,
+	)
+	return nil, err
+}
+
+// Revoke moves a role out of the access group and into the default one. A
+// role can't be removed from the default access group, only moved to another
+// one by granting its membership.
+func (o *accessGroupBuilder) Revoke(
+	ctx context.Context,
+	grant *v2.Grant,
+) (annotations.Annotations, error) {
+	logger := ctxzap.Extract(ctx)
+
+	principal := grant.Principal
+	accessGroupId := grant.Entitlement.Resource.Id.Resource
+	if principal.Id.ResourceType != roleResourceType.Id {
+		return nil, status.Error(codes.InvalidArgument, "baton-privx: only roles can be moved out of access groups")
+	}
+
+	role, rateLimit, err := o.client.GetRole(ctx, principal.Id.Resource)
+	if err != nil {
+		return rateLimitAnnotations(rateLimit), err
+	}
+	if role.AccessGroupID != accessGroupId {
+		logger.Info(
+			"baton-privx: role is not in the access group, nothing to revoke",
+			zap.String("role_id", principal.Id.Resource),
+			zap.String("access_group_id", accessGroupId),
+		)
+		return nil, nil
+	}
+
+	defaultAccessGroup, err := o.client.GetDefaultAccessGroup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if defaultAccessGroup.ID == accessGroupId {
+		return nil, status.Error(
+			codes.FailedPrecondition,
+			"baton-privx: roles can't be removed from the default access group, grant another access group instead",
+		)
+	}
+
+	err = o.client.SetRoleAccessGroup(ctx, principal.Id.Resource, defaultAccessGroup.ID)
+	return nil, err
+}
+
+func newAccessGroupBuilder(client client.PrivXClient) *accessGroupBuilder {
+	return &accessGroupBuilder{client: client}
+}
+
+// accessGroupResource Converts a PrivX access group into a ConductorOne
+// Resource.
+func accessGroupResource(ctx context.Context, accessGroup *authorizer.AccessGroup) (*v2.Resource, error) {
+	createdResource, err := resource.NewGroupResource(
+		accessGroup.Name,
+		accessGroupResourceType,
+		accessGroup.ID,
+		[]resource.GroupTraitOption{
+			resource.WithGroupProfile(
+				map[string]interface{}{
+					"name":       accessGroup.Name,
+					"comment":    accessGroup.Comment,
+					"default":    accessGroup.Default,
+					"ca_id":      accessGroup.CAID,
+					"author":     accessGroup.Author,
+					"created":    accessGroup.Created,
+					"updated":    accessGroup.Updated,
+					"updated_by": accessGroup.UpdatedBy,
+				},
+			),
+		},
+		resource.WithDescription(accessGroup.Comment),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
@@ -0,0 +1,230 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	resourceSdk "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessGroupsList(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/access_groups_page_0.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	accessGroupBuilder := newAccessGroupBuilder(*privXClient)
+
+	resources, nextToken, _, err := accessGroupBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, "", nextToken)
+	require.Len(t, resources, 2)
+	require.Equal(t, "Network team", resources[1].DisplayName)
+
+	groupTrait, err := resourceSdk.GetGroupTrait(resources[0])
+	require.Nil(t, err)
+	profile := groupTrait.Profile.AsMap()
+	require.Equal(t, true, profile["default"])
+	require.Equal(t, "5e0f4b8a-7c3d-4f2a-9b1e-6a8d2c4f0b33", profile["ca_id"])
+}
+
+const (
+	defaultAccessGroupId = "d1b2f6e4-0d5c-4b6b-8d3c-0f2b7c1a9e11"
+	networkAccessGroupId = "8a4c2e6f-1b3d-4e5f-a7b9-c0d2e4f6a8b0"
+)
+
+// fakeRoleStore serves the roles of the roles fixture and the access groups
+// of the access groups fixture, and lets roles be updated.
+type fakeRoleStore struct {
+	t         *testing.T
+	roles     []rolestore.Role
+	roleLists int
+	roleReads int
+	puts      int
+}
+
+func newFakeRoleStore(t *testing.T) *fakeRoleStore {
+	data, err := os.ReadFile("./client/fixtures/roles_page_0.json")
+	require.Nil(t, err)
+	var page struct {
+		Items []rolestore.Role `json:"items"`
+	}
+	require.Nil(t, json.Unmarshal(data, &page))
+	return &fakeRoleStore{t: t, roles: page.Items}
+}
+
+func (f *fakeRoleStore) role(roleId string) *rolestore.Role {
+	for i := range f.roles {
+		if f.roles[i].ID == roleId {
+			return &f.roles[i]
+		}
+	}
+	require.FailNow(f.t, "unknown role", roleId)
+	return nil
+}
+
+func (f *fakeRoleStore) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set(uhttp.ContentType, "application/json")
+	switch {
+	case request.URL.Path == "/authorizer/api/v1/accessgroups":
+		json, err := os.ReadFile("./client/fixtures/access_groups_page_0.json")
+		require.Nil(f.t, err)
+		_, _ = writer.Write(json)
+	case strings.HasSuffix(request.URL.Path, "/members"):
+		_, _ = writer.Write([]byte(`{"count": 0, "items": []}`))
+	case request.URL.Path == "/role-store/api/v1/roles":
+		f.roleLists++
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{"count": len(f.roles), "items": f.roles})
+	case strings.HasPrefix(request.URL.Path, "/role-store/api/v1/roles/") && request.Method == http.MethodPut:
+		f.puts++
+		role := f.role(strings.TrimPrefix(request.URL.Path, "/role-store/api/v1/roles/"))
+		require.Nil(f.t, json.NewDecoder(request.Body).Decode(role))
+	case strings.HasPrefix(request.URL.Path, "/role-store/api/v1/roles/"):
+		f.roleReads++
+		_ = json.NewEncoder(writer).Encode(f.role(strings.TrimPrefix(request.URL.Path, "/role-store/api/v1/roles/")))
+	default:
+		_, _ = writer.Write([]byte(`{}`))
+	}
+}
+
+func TestAccessGroupsGrants(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRoleStore(t)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	accessGroupBuilder := newAccessGroupBuilder(*privXClient)
+
+	_, _, _, err = accessGroupBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+
+	accessGroup := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: accessGroupResourceType.Id, Resource: "fcde7572-9781-4d47-bc1d-7977afb11dc3"},
+	}
+	grants, nextToken, _, err := accessGroupBuilder.Grants(ctx, accessGroup, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, "", nextToken)
+	require.Len(t, grants, 2)
+	require.Equal(t, "access_group:fcde7572-9781-4d47-bc1d-7977afb11dc3:member", grants[0].Entitlement.Id)
+	require.Equal(t, roleResourceType.Id, grants[0].Principal.Id.ResourceType)
+	require.Equal(t, "7a32374b-d7c5-4e76-9a16-005f39692f63", grants[0].Principal.Id.Resource)
+	require.Equal(t, "a382cd90-336e-41d2-9432-f28e9afe85c9", grants[1].Principal.Id.Resource)
+
+	accessGroup = &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: accessGroupResourceType.Id, Resource: "9030b77b-3360-4dea-7efc-4cd5c818b49b"},
+	}
+	grants, _, _, err = accessGroupBuilder.Grants(ctx, accessGroup, &pagination.Token{})
+	require.Nil(t, err)
+	require.Len(t, grants, 1)
+	require.Equal(t, "3453395a-2a12-50a5-4fdb-794d567edae0", grants[0].Principal.Id.Resource)
+
+	// The roles are read once per sync.
+	require.Equal(t, 1, fake.roleLists)
+}
+
+func TestAccessGroupsGrantRevoke(t *testing.T) {
+	ctx := context.Background()
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+
+	newBuilder := func(t *testing.T) (*accessGroupBuilder, *fakeRoleStore) {
+		fake := newFakeRoleStore(t)
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		privXClient, err := client.NewPrivXClient(
+			ctx,
+			server.URL,
+			"apiClientId",
+			"apiClientSecret",
+			"oauthClientId",
+			"oauthClientSecret",
+		)
+		require.Nil(t, err)
+		return newAccessGroupBuilder(*privXClient), fake
+	}
+	role := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+	}
+	memberOf := func(accessGroupId string) *v2.Entitlement {
+		accessGroup := &v2.Resource{
+			Id: &v2.ResourceId{ResourceType: accessGroupResourceType.Id, Resource: accessGroupId},
+		}
+		return &v2.Entitlement{
+			Id:       entitlement.NewEntitlementID(accessGroup, EntitlementMember),
+			Resource: accessGroup,
+		}
+	}
+
+	t.Run("should move a role to the access group", func(t *testing.T) {
+		accessGroupBuilder, fake := newBuilder(t)
+
+		_, err := accessGroupBuilder.Grant(ctx, role, memberOf(networkAccessGroupId))
+		require.Nil(t, err)
+		require.Equal(t, 1, fake.puts)
+		require.Equal(t, networkAccessGroupId, fake.role(roleId).AccessGroupID)
+	})
+
+	t.Run("should move a role back to the default access group", func(t *testing.T) {
+		accessGroupBuilder, fake := newBuilder(t)
+		fake.role(roleId).AccessGroupID = networkAccessGroupId
+
+		_, err := accessGroupBuilder.Revoke(ctx, &v2.Grant{Entitlement: memberOf(networkAccessGroupId), Principal: role})
+		require.Nil(t, err)
+		require.Equal(t, 1, fake.puts)
+		require.Equal(t, defaultAccessGroupId, fake.role(roleId).AccessGroupID)
+	})
+
+	t.Run("should refuse to remove a role from the default access group", func(t *testing.T) {
+		accessGroupBuilder, fake := newBuilder(t)
+		fake.role(roleId).AccessGroupID = defaultAccessGroupId
+
+		_, err := accessGroupBuilder.Revoke(ctx, &v2.Grant{Entitlement: memberOf(defaultAccessGroupId), Principal: role})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.Equal(t, 0, fake.puts)
+		require.Equal(t, defaultAccessGroupId, fake.role(roleId).AccessGroupID)
+	})
+}
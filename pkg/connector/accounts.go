@@ -0,0 +1,280 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Keys of the account profile read when creating a local user.
+const (
+	accountFullNameKey = "full_name"
+	accountRolesKey    = "roles"
+)
+
+// CreateAccount creates a user in the PrivX local user store from the login,
+// primary email and profile of the account. The profile may hold the user's
+// `full_name` and the IDs of the `roles` to grant them, as a list or a comma
+// separated string. The user gets a random password if the credential
+// options ask for one, or none otherwise. If a role can't be granted the user
+// is deleted again, so that a failed request leaves no account behind.
+func (o *userBuilder) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	credentialOptions *v2.CredentialOptions,
+) (
+	connectorbuilder.CreateAccountResponse,
+	[]*v2.PlaintextData,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	username := accountInfo.GetLogin()
+	if username == "" {
+		return nil, nil, nil, status.Error(codes.InvalidArgument, "baton-privx: a login is required to create an account")
+	}
+
+	fields := accountInfo.GetProfile().GetFields()
+	localUser := userstore.LocalUser{
+		Username: username,
+		FullName: fields[accountFullNameKey].GetStringValue(),
+		Email:    accountEmail(accountInfo),
+	}
+	if localUser.FullName == "" {
+		localUser.FullName = username
+	}
+
+	// Without credential options the user is created without a password.
+	var plaintexts []*v2.PlaintextData
+	switch credentialOptions.GetOptions().(type) {
+	case nil:
+	case *v2.CredentialOptions_RandomPassword_:
+		randomPassword := credentialOptions.GetRandomPassword()
+		if randomPassword == nil {
+			return nil, nil, nil, status.Error(codes.InvalidArgument, "baton-privx: random password options are required")
+		}
+		password, err := randomCredential(randomPassword)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		localUser.Password = userstore.Password{Password: password}
+		plaintexts = append(plaintexts, &v2.PlaintextData{
+			Name:        "password",
+			Description: "Password of the PrivX local user",
+			Bytes:       []byte(password),
+		})
+	default:
+		return nil, nil, nil, status.Errorf(
+			codes.InvalidArgument,
+			"baton-privx: unsupported credential option %T",
+			credentialOptions.GetOptions(),
+		)
+	}
+
+	userId, err := o.client.CreateLocalUser(ctx, localUser)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, roleId := range accountRoleIds(fields[accountRolesKey]) {
+		err = o.client.GrantRole(ctx, userId, roleId)
+		if err != nil {
+			deleteErr := o.client.DeleteLocalUser(ctx, userId)
+			if deleteErr != nil {
+				logger.Error(
+					"baton-privx: failed deleting user after failing to grant them a role",
+					zap.String("user_id", userId),
+					zap.String("role_id", roleId),
+					zap.Error(deleteErr),
+				)
+				return nil, nil, nil, fmt.Errorf(
+					"baton-privx: created user %s but failed to grant role %s: %w, and failed to delete the user: %w",
+					userId,
+					roleId,
+					err,
+					deleteErr,
+				)
+			}
+			return nil, nil, nil, fmt.Errorf("baton-privx: failed to grant role %s to new user %s, the user was deleted: %w", roleId, userId, err)
+		}
+	}
+
+	user, rateLimit, err := o.client.GetUser(ctx, userId)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		// The role-store may not have picked up the new user yet, the next
+		// sync will fill in what it knows about them.
+		logger.Warn(
+			"baton-privx: created user not found in the role-store",
+			zap.String("user_id", userId),
+			zap.Error(err),
+		)
+		user = &rolestore.User{
+			ID:        userId,
+			Principal: localUser.Username,
+			FullName:  localUser.FullName,
+			Email:     localUser.Email,
+		}
+	}
+
+	newUserResource, err := userResource(ctx, user, nil, nil, nil)
+	if err != nil {
+		return nil, nil, outputAnnotations, err
+	}
+
+	return &v2.CreateAccountResponse_SuccessResult{
+		Resource:              newUserResource,
+		IsCreateAccountResult: true,
+	}, plaintexts, outputAnnotations, nil
+}
+
+// accountEmail returns the primary email of an account, or its first one.
+func accountEmail(accountInfo *v2.AccountInfo) string {
+	emails := accountInfo.GetEmails()
+	for _, email := range emails {
+		if email.GetIsPrimary() {
+			return email.GetAddress()
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].GetAddress()
+	}
+	return ""
+}
+
+// accountRoleIds reads the role IDs of an account profile, given either as a
+// list or as a comma separated string.
+func accountRoleIds(value *structpb.Value) []string {
+	var roleIds []string
+	switch kind := value.GetKind().(type) {
+	case *structpb.Value_ListValue:
+		for _, item := range kind.ListValue.GetValues() {
+			if roleId := strings.TrimSpace(item.GetStringValue()); roleId != "" {
+				roleIds = append(roleIds, roleId)
+			}
+		}
+	case *structpb.Value_StringValue:
+		for _, roleId := range strings.Split(kind.StringValue, ",") {
+			if roleId = strings.TrimSpace(roleId); roleId != "" {
+				roleIds = append(roleIds, roleId)
+			}
+		}
+	}
+	return roleIds
+}
+
+// Create is required by connectorbuilder.ResourceManager, users are created
+// through CreateAccount instead.
+func (o *userBuilder) Create(ctx context.Context, resource *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	return nil, nil, status.Error(codes.Unimplemented, "baton-privx: users are created with CreateAccount")
+}
+
+// Delete deprovisions a user. Users of the PrivX local user store and API
+// clients are deleted, after terminating their sessions. Directory users
+// can't be deleted in PrivX, so their explicit roles are revoked and their
+// sessions terminated instead, and the returned annotation says that the
+// account must be disabled in the directory. Sessions are terminated
+// whatever --terminate-sessions says, a deprovisioned user must not keep
+// them. The API client the connector authenticates as isn't deleted.
+func (o *userBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	logger := ctxzap.Extract(ctx)
+	userId := resourceId.Resource
+
+	if o.client.IsConnectorAPIClient(userId) {
+		return nil, status.Errorf(
+			codes.FailedPrecondition,
+			"baton-privx: API client %s is the one the connector authenticates as and can't be deleted",
+			userId,
+		)
+	}
+
+	_, err := o.client.GetLocalUser(ctx, userId)
+	switch {
+	case err == nil:
+		err = o.terminateSessions(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = o.client.DeleteLocalUser(ctx, userId)
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	case status.Code(err) != codes.NotFound:
+		return nil, err
+	}
+
+	// API clients aren't local users but show up in the role-store like
+	// directory users do.
+	isAPIClient, err := userIsAPIClient(ctx, o.client, userId)
+	if err != nil {
+		return nil, err
+	}
+	if isAPIClient {
+		err = o.terminateSessions(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = o.client.DeleteAPIClient(ctx, userId)
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = o.client.RevokeExplicitRoles(ctx, userId)
+	if status.Code(err) == codes.NotFound {
+		// Not a local user nor a role-store user, already gone.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.terminateSessions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf(
+		"PrivX user %s comes from a directory source and can't be deleted in PrivX. "+
+			"Its explicit roles were revoked and its sessions terminated, "+
+			"the account must be disabled in the directory.",
+		userId,
+	)
+	logger.Warn(
+		"baton-privx: directory user must be disabled upstream",
+		zap.String("user_id", userId),
+	)
+
+	return annotations.New(&structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"action_required": structpb.NewStringValue("disable_upstream"),
+			"user_id":         structpb.NewStringValue(userId),
+			"message":         structpb.NewStringValue(message),
+		},
+	}), nil
+}
+
+// terminateSessions ends the sessions of a user, who may have none.
+func (o *userBuilder) terminateSessions(ctx context.Context, userId string) error {
+	err := o.client.TerminateUserSessions(ctx, userId)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
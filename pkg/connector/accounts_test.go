@@ -0,0 +1,387 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestAccountRoleIds(t *testing.T) {
+	listValue, err := structpb.NewValue([]interface{}{"role-a", " role-b "})
+	require.Nil(t, err)
+	require.Equal(t, []string{"role-a", "role-b"}, accountRoleIds(listValue))
+
+	require.Equal(t, []string{"role-a", "role-b"}, accountRoleIds(structpb.NewStringValue("role-a, role-b,")))
+	require.Nil(t, accountRoleIds(nil))
+}
+
+func TestCreateAccount(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				_, err := writer.Write([]byte(`{"id": "0c7e3f2a-5b1d-4c8e-9f6a-2d4b8e0c1a35", "principal": "contractor"}`))
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	t.Run("should return a random password", func(t *testing.T) {
+		result, plaintexts, _, err := userBuilder.CreateAccount(
+			ctx,
+			&v2.AccountInfo{Login: "contractor"},
+			&v2.CredentialOptions{
+				Options: &v2.CredentialOptions_RandomPassword_{
+					RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16},
+				},
+			},
+		)
+		require.Nil(t, err)
+		require.Len(t, plaintexts, 1)
+		require.Len(t, plaintexts[0].Bytes, 16)
+
+		success, ok := result.(*v2.CreateAccountResponse_SuccessResult)
+		require.True(t, ok)
+		require.Equal(t, "0c7e3f2a-5b1d-4c8e-9f6a-2d4b8e0c1a35", success.Resource.Id.Resource)
+	})
+
+	t.Run("should create a user without a password", func(t *testing.T) {
+		_, plaintexts, _, err := userBuilder.CreateAccount(ctx, &v2.AccountInfo{Login: "contractor"}, nil)
+		require.Nil(t, err)
+		require.Len(t, plaintexts, 0)
+	})
+
+	t.Run("should require a login", func(t *testing.T) {
+		_, _, _, err := userBuilder.CreateAccount(ctx, &v2.AccountInfo{}, nil)
+		require.NotNil(t, err)
+	})
+
+	t.Run("should reject random password without options", func(t *testing.T) {
+		_, _, _, err := userBuilder.CreateAccount(
+			ctx,
+			&v2.AccountInfo{Login: "contractor"},
+			&v2.CredentialOptions{Options: &v2.CredentialOptions_RandomPassword_{}},
+		)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestCreateAccountRoles(t *testing.T) {
+	ctx := context.Background()
+	userId := "0c7e3f2a-5b1d-4c8e-9f6a-2d4b8e0c1a35"
+	newServer := func(t *testing.T, putRoles *[]rolestore.Role, deleted *bool) *httptest.Server {
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					writer.Header().Set(uhttp.ContentType, "application/json")
+					switch {
+					case request.URL.Path == "/local-user-store/api/v1/users" && request.Method == http.MethodPost:
+						_, _ = writer.Write([]byte(`{"id": "` + userId + `"}`))
+					case request.URL.Path == "/local-user-store/api/v1/users/"+userId && request.Method == http.MethodDelete:
+						*deleted = true
+					case request.URL.Path == "/role-store/api/v1/roles/missing-role":
+						writer.WriteHeader(http.StatusNotFound)
+						_, _ = writer.Write([]byte(`{"error_code": "NOT_FOUND"}`))
+					case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && request.Method == http.MethodPut:
+						require.Nil(t, json.NewDecoder(request.Body).Decode(putRoles))
+					case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+						roles := *putRoles
+						if roles == nil {
+							roles = []rolestore.Role{}
+						}
+						_ = json.NewEncoder(writer).Encode(map[string]interface{}{"items": roles})
+					case request.URL.Path == "/role-store/api/v1/users/"+userId:
+						_, _ = writer.Write([]byte(`{"id": "` + userId + `", "principal": "contractor"}`))
+					default:
+						_, _ = writer.Write([]byte(`{}`))
+					}
+				},
+			),
+		)
+		t.Cleanup(server.Close)
+		return server
+	}
+	newBuilder := func(t *testing.T, server *httptest.Server) *userBuilder {
+		privXClient, err := client.NewPrivXClient(
+			ctx,
+			server.URL,
+			"apiClientId",
+			"apiClientSecret",
+			"oauthClientId",
+			"oauthClientSecret",
+		)
+		require.Nil(t, err)
+		return newUserBuilder(*privXClient)
+	}
+	accountInfo := func(t *testing.T, roles string) *v2.AccountInfo {
+		profile, err := structpb.NewStruct(map[string]interface{}{"roles": roles})
+		require.Nil(t, err)
+		return &v2.AccountInfo{Login: "contractor", Profile: profile}
+	}
+
+	t.Run("should grant the roles of the profile", func(t *testing.T) {
+		var putRoles []rolestore.Role
+		deleted := false
+		userBuilder := newBuilder(t, newServer(t, &putRoles, &deleted))
+
+		result, _, _, err := userBuilder.CreateAccount(ctx, accountInfo(t, "role-a,role-b"), nil)
+		require.Nil(t, err)
+		_, ok := result.(*v2.CreateAccountResponse_SuccessResult)
+		require.True(t, ok)
+
+		require.Len(t, putRoles, 2)
+		require.Equal(t, "role-a", putRoles[0].ID)
+		require.True(t, putRoles[0].Explicit)
+		require.Equal(t, "role-b", putRoles[1].ID)
+		require.True(t, putRoles[1].Explicit)
+		require.False(t, deleted)
+	})
+
+	t.Run("should delete the user when a role can't be granted", func(t *testing.T) {
+		var putRoles []rolestore.Role
+		deleted := false
+		userBuilder := newBuilder(t, newServer(t, &putRoles, &deleted))
+
+		result, _, _, err := userBuilder.CreateAccount(ctx, accountInfo(t, "role-a,missing-role"), nil)
+		require.NotNil(t, err)
+		require.Nil(t, result)
+		require.Len(t, putRoles, 1)
+		require.True(t, deleted)
+	})
+}
+
+func TestDeleteDirectoryUser(t *testing.T) {
+	ctx := context.Background()
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	var putRoles []rolestore.Role
+	terminated := false
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/local-user-store/api/v1/users/"+userId,
+					request.URL.Path == "/local-user-store/api/v1/api-clients/"+userId:
+					writer.WriteHeader(http.StatusNotFound)
+					_, _ = writer.Write([]byte(`{"error_code": "NOT_FOUND"}`))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && request.Method == http.MethodPut:
+					require.Nil(t, json.NewDecoder(request.Body).Decode(&putRoles))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+					roles := []rolestore.Role{
+						{ID: "explicit-role", Explicit: true},
+						{ID: "implicit-role", Implicit: true},
+					}
+					if putRoles != nil {
+						roles = putRoles
+					}
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{"items": roles})
+				case request.URL.Path == "/auth/api/v1/sessionstorage/users/"+userId+"/sessions/terminate":
+					terminated = true
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	outputAnnotations, err := userBuilder.Delete(ctx, &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId})
+	require.Nil(t, err)
+	require.Len(t, putRoles, 1)
+	require.Equal(t, "implicit-role", putRoles[0].ID)
+	require.True(t, terminated)
+
+	message := &structpb.Struct{}
+	ok, err := outputAnnotations.Pick(message)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "disable_upstream", message.Fields["action_required"].GetStringValue())
+}
+
+func TestDeleteDirectoryUserWithoutAPIClientAccess(t *testing.T) {
+	ctx := context.Background()
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	var putRoles []rolestore.Role
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/local-user-store/api/v1/users/"+userId:
+					writer.WriteHeader(http.StatusNotFound)
+					_, _ = writer.Write([]byte(`{"error_code": "NOT_FOUND"}`))
+				case request.URL.Path == "/local-user-store/api/v1/api-clients/"+userId:
+					writer.WriteHeader(http.StatusForbidden)
+					_, _ = writer.Write([]byte(`{"error_code": "FORBIDDEN"}`))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && request.Method == http.MethodPut:
+					require.Nil(t, json.NewDecoder(request.Body).Decode(&putRoles))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+					roles := []rolestore.Role{{ID: "explicit-role", Explicit: true}}
+					if putRoles != nil {
+						roles = putRoles
+					}
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{"items": roles})
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	// Without access to API clients the user is taken for a directory user.
+	outputAnnotations, err := userBuilder.Delete(ctx, &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId})
+	require.Nil(t, err)
+	require.NotNil(t, putRoles)
+	require.Len(t, putRoles, 0)
+
+	message := &structpb.Struct{}
+	ok, err := outputAnnotations.Pick(message)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "disable_upstream", message.Fields["action_required"].GetStringValue())
+}
+
+func TestDeleteLocalUser(t *testing.T) {
+	ctx := context.Background()
+	userId := "0c7e3f2a-5b1d-4c8e-9f6a-2d4b8e0c1a35"
+	var calls []string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/local-user-store/api/v1/users/"+userId && request.Method == http.MethodDelete:
+					calls = append(calls, "delete")
+				case request.URL.Path == "/local-user-store/api/v1/users/"+userId:
+					_, _ = writer.Write([]byte(`{"id": "` + userId + `", "username": "contractor"}`))
+				case request.URL.Path == "/auth/api/v1/sessionstorage/users/"+userId+"/sessions/terminate":
+					calls = append(calls, "terminate")
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+					require.Fail(t, "local users are deleted, not stripped of their roles")
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	outputAnnotations, err := userBuilder.Delete(ctx, &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId})
+	require.Nil(t, err)
+	require.Len(t, outputAnnotations, 0)
+	require.Equal(t, []string{"terminate", "delete"}, calls)
+}
+
+func TestDeleteAPIClient(t *testing.T) {
+	ctx := context.Background()
+	apiClientId := "bc0e7972-8f4f-43a0-795a-abfa0ed0309a"
+	var calls []string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/local-user-store/api/v1/users/"+apiClientId:
+					writer.WriteHeader(http.StatusNotFound)
+					_, _ = writer.Write([]byte(`{"error_code": "NOT_FOUND"}`))
+				case request.URL.Path == "/local-user-store/api/v1/api-clients/"+apiClientId && request.Method == http.MethodDelete:
+					calls = append(calls, "delete")
+				case request.URL.Path == "/local-user-store/api/v1/api-clients/"+apiClientId:
+					_, _ = writer.Write([]byte(`{"id": "` + apiClientId + `", "name": "deploy-bot"}`))
+				case request.URL.Path == "/auth/api/v1/sessionstorage/users/"+apiClientId+"/sessions/terminate":
+					calls = append(calls, "terminate")
+				case request.URL.Path == "/role-store/api/v1/users/"+apiClientId+"/roles":
+					require.Fail(t, "API clients are deleted, not treated as directory users")
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	outputAnnotations, err := userBuilder.Delete(ctx, &v2.ResourceId{ResourceType: userResourceType.Id, Resource: apiClientId})
+	require.Nil(t, err)
+	require.Len(t, outputAnnotations, 0)
+	require.Equal(t, []string{"terminate", "delete"}, calls)
+
+	t.Run("should refuse the API client the connector authenticates as", func(t *testing.T) {
+		calls = nil
+
+		_, err := userBuilder.Delete(ctx, &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "apiClientId"})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.Empty(t, calls)
+	})
+}
@@ -0,0 +1,72 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// lastConnectionWindow bounds how far back host connections are scanned for.
+const lastConnectionWindow = 90 * 24 * time.Hour
+
+// userActivity is when users last logged in to PrivX and last connected to a
+// host through it, scanned in bulk once per sync rather than per user.
+type userActivity struct {
+	lastLogins      map[string]time.Time
+	lastConnections map[string]time.Time
+}
+
+// getUserActivity scans the auth sessions and the recent host connections.
+// Either scan needs more permissions than the user search, so if one is
+// denied users are synced without that part of their activity.
+func getUserActivity(ctx context.Context, privXClient client.PrivXClient) (*userActivity, error) {
+	logger := ctxzap.Extract(ctx)
+	activity := &userActivity{}
+
+	lastLogins, err := privXClient.GetLastLogins(ctx)
+	switch {
+	case status.Code(err) == codes.PermissionDenied:
+		logger.Warn("baton-privx: not allowed to list sessions, syncing users without their last login", zap.Error(err))
+	case err != nil:
+		logger.Debug("Error fetching sessions", zap.Error(err))
+		return nil, err
+	default:
+		activity.lastLogins = lastLogins
+	}
+
+	lastConnections, err := privXClient.GetLastConnections(ctx, time.Now().Add(-lastConnectionWindow))
+	switch {
+	case status.Code(err) == codes.PermissionDenied:
+		logger.Warn("baton-privx: not allowed to list connections, syncing users without their last connection", zap.Error(err))
+	case err != nil:
+		logger.Debug("Error fetching connections", zap.Error(err))
+		return nil, err
+	default:
+		activity.lastConnections = lastConnections
+	}
+
+	return activity, nil
+}
+
+// lastLogin returns when the user last logged in to PrivX, or the zero time
+// if that isn't known.
+func (a *userActivity) lastLogin(userId string) time.Time {
+	if a == nil {
+		return time.Time{}
+	}
+	return a.lastLogins[userId]
+}
+
+// lastConnection returns when the user last connected to a host, or the zero
+// time if that isn't known.
+func (a *userActivity) lastConnection(userId string) time.Time {
+	if a == nil {
+		return time.Time{}
+	}
+	return a.lastConnections[userId]
+}
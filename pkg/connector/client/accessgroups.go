@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetAccessGroups uses pagination to get a list of the access groups that
+// scope PrivX management rights.
+func (c *PrivXClient) GetAccessGroups(
+	ctx context.Context,
+	offset int,
+	limit int,
+) (
+	[]authorizer.AccessGroup,
+	string,
+	*v2.RateLimitDescription,
+	error,
+) {
+	api := c.connector(ctx)
+	accessGroups, err := authorizer.New(api).AccessGroups(offset, limit, "", "")
+	if err != nil {
+		return nil, "", api.RateLimit(), err
+	}
+
+	nextToken := getNextToken(offset, len(accessGroups), limit)
+
+	return accessGroups, nextToken, api.RateLimit(), nil
+}
+
+// GetDefaultAccessGroup pages through the access groups and returns the
+// default one, which new roles are placed in.
+func (c *PrivXClient) GetDefaultAccessGroup(ctx context.Context) (*authorizer.AccessGroup, error) {
+	offset := 0
+	for {
+		accessGroups, nextToken, _, err := c.GetAccessGroups(ctx, offset, allPagesLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range accessGroups {
+			if accessGroups[i].Default {
+				return &accessGroups[i], nil
+			}
+		}
+
+		if nextToken == "" {
+			return nil, status.Error(codes.NotFound, "no default access group")
+		}
+		offset += len(accessGroups)
+	}
+}
+
+// SetRoleAccessGroup moves a role to the given access group. The update is
+// conflict-safe, see updateRole.
+func (c *PrivXClient) SetRoleAccessGroup(ctx context.Context, roleId, accessGroupId string) error {
+	return c.updateRole(ctx, roleId, func(role *rolestore.Role) bool {
+		if role.AccessGroupID == accessGroupId {
+			return false
+		}
+		role.AccessGroupID = accessGroupId
+		return true
+	})
+}
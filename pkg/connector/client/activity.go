@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+)
+
+// GetLastLogins pages through the sessions kept by the auth service and
+// returns when each user last logged in. The auth service only keeps sessions
+// until they expire, so users that haven't logged in since are missing.
+func (c *PrivXClient) GetLastLogins(ctx context.Context) (map[string]time.Time, error) {
+	lastLogins := make(map[string]time.Time)
+	offset := 0
+	for {
+		sessions, nextToken, _, err := c.GetSessions(ctx, offset, allPagesLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, session := range sessions {
+			if session.Created.After(lastLogins[session.UserID]) {
+				lastLogins[session.UserID] = session.Created
+			}
+		}
+
+		if nextToken == "" {
+			return lastLogins, nil
+		}
+		offset += len(sessions)
+	}
+}
+
+// GetLastConnections pages through the host connections made since the given
+// time and returns when each user last connected to a host. Connections are
+// paged oldest first, like sessions in GetSessions.
+func (c *PrivXClient) GetLastConnections(ctx context.Context, since time.Time) (map[string]time.Time, error) {
+	lastConnections := make(map[string]time.Time)
+	search := connectionmanager.ConnectionSearch{
+		Connected: connectionmanager.TimestampSearch{
+			Start: since.UTC().Format(time.RFC3339),
+		},
+	}
+	offset := 0
+	for {
+		connections, err := connectionmanager.New(c.connector(ctx)).SearchConnections(
+			offset,
+			allPagesLimit,
+			"ASC",
+			"connected",
+			false,
+			search,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, connection := range connections {
+			connected, err := time.Parse(time.RFC3339Nano, connection.Connected)
+			if err != nil {
+				continue
+			}
+			if connected.After(lastConnections[connection.UserData.ID]) {
+				lastConnections[connection.UserData.ID] = connected
+			}
+		}
+
+		nextToken := getNextToken(offset, len(connections), allPagesLimit)
+		if nextToken == "" {
+			return lastConnections, nil
+		}
+		offset += len(connections)
+	}
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/auth"
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeActivity serves sessions and connections sorted and paged like PrivX
+// does. afterFirstPage, if set, runs once the first page of either has been
+// served and may record new activity like a concurrent login would.
+type fakeActivity struct {
+	sessions       []auth.Session
+	connections    []connectionmanager.Connection
+	searches       []activitySearch
+	afterFirstPage func(f *fakeActivity)
+}
+
+// activitySearch is the part of a search request that pages through the
+// results.
+type activitySearch struct {
+	path    string
+	offset  int
+	sortdir string
+}
+
+func (f *fakeActivity) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	sortdir := query.Get("sortdir")
+	f.searches = append(f.searches, activitySearch{path: request.URL.Path, offset: offset, sortdir: sortdir})
+
+	page := func(count int) (int, int) {
+		start := min(offset, count)
+		return start, min(start+limit, count)
+	}
+	switch request.URL.Path {
+	case "/auth/api/v1/sessionstorage/sessions/search":
+		sessions := slices.Clone(f.sessions)
+		slices.SortFunc(sessions, func(a, b auth.Session) int { return a.Created.Compare(b.Created) })
+		if sortdir == "DESC" {
+			slices.Reverse(sessions)
+		}
+		start, end := page(len(sessions))
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+			"count": len(sessions),
+			"items": sessions[start:end],
+		})
+	case "/connection-manager/api/v1/connections/search":
+		connections := slices.Clone(f.connections)
+		slices.SortFunc(connections, func(a, b connectionmanager.Connection) int {
+			if sortdir == "DESC" {
+				a, b = b, a
+			}
+			if a.Connected < b.Connected {
+				return -1
+			}
+			if a.Connected > b.Connected {
+				return 1
+			}
+			return 0
+		})
+		start, end := page(len(connections))
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+			"count": len(connections),
+			"items": connections[start:end],
+		})
+	default:
+		_, _ = writer.Write([]byte(`{}`))
+		return
+	}
+
+	if offset == 0 && f.afterFirstPage != nil {
+		f.afterFirstPage(f)
+	}
+}
+
+func TestGetLastLogins(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeActivity{}
+	// More sessions than fit a page, alternating between two users.
+	for i := 0; i < allPagesLimit+20; i++ {
+		fake.sessions = append(fake.sessions, auth.Session{
+			ID:      fmt.Sprintf("session-%d", i),
+			UserID:  fmt.Sprintf("user-%d", i%2),
+			Created: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	loggedInWhilePaging := start.Add(24 * time.Hour)
+	fake.afterFirstPage = func(f *fakeActivity) {
+		f.sessions = append(f.sessions, auth.Session{ID: "session-new", UserID: "user-2", Created: loggedInWhilePaging})
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+	lastLogins, err := privXClient.GetLastLogins(ctx)
+	require.Nil(t, err)
+
+	// Every page is read oldest first, so the login made while paging is
+	// found on the last page rather than shifting the others.
+	require.Len(t, fake.searches, 2)
+	require.Equal(t, "ASC", fake.searches[0].sortdir)
+	require.Equal(t, allPagesLimit, fake.searches[1].offset)
+	require.Equal(t, start.Add(time.Duration(allPagesLimit+18)*time.Minute), lastLogins["user-0"])
+	require.Equal(t, start.Add(time.Duration(allPagesLimit+19)*time.Minute), lastLogins["user-1"])
+	require.Equal(t, loggedInWhilePaging, lastLogins["user-2"])
+}
+
+func TestGetLastConnections(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	connection := func(i int, userId string, connected time.Time) connectionmanager.Connection {
+		return connectionmanager.Connection{
+			ID:        fmt.Sprintf("connection-%d", i),
+			Connected: connected.Format(time.RFC3339Nano),
+			UserData:  connectionmanager.UserData{ID: userId},
+		}
+	}
+	fake := &fakeActivity{}
+	for i := 0; i < allPagesLimit+20; i++ {
+		fake.connections = append(fake.connections, connection(i, fmt.Sprintf("user-%d", i%2), start.Add(time.Duration(i)*time.Minute)))
+	}
+	connectedWhilePaging := start.Add(24 * time.Hour)
+	fake.afterFirstPage = func(f *fakeActivity) {
+		f.connections = append(f.connections, connection(-1, "user-2", connectedWhilePaging))
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+	lastConnections, err := privXClient.GetLastConnections(ctx, start)
+	require.Nil(t, err)
+
+	require.Len(t, fake.searches, 2)
+	require.Equal(t, "ASC", fake.searches[0].sortdir)
+	require.Equal(t, allPagesLimit, fake.searches[1].offset)
+	require.Equal(t, start.Add(time.Duration(allPagesLimit+18)*time.Minute), lastConnections["user-0"])
+	require.Equal(t, start.Add(time.Duration(allPagesLimit+19)*time.Minute), lastConnections["user-1"])
+	require.Equal(t, connectedWhilePaging, lastConnections["user-2"])
+}
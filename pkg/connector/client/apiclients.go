@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// APIClient is a PrivX API client, a non-human identity like the one this
+// connector authenticates as. Unlike userstore.APIClient it never holds the
+// client's secrets.
+type APIClient struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Roles     []rolestore.RoleRef `json:"roles,omitempty"`
+	Created   string              `json:"created,omitempty"`
+	Author    string              `json:"author,omitempty"`
+	Updated   string              `json:"updated,omitempty"`
+	UpdatedBy string              `json:"updated_by,omitempty"`
+}
+
+type apiClientsResult struct {
+	Count int         `json:"count"`
+	Items []APIClient `json:"items"`
+}
+
+// GetAPIClients fetches every API client registered in PrivX. They are served
+// by the local user store, which doesn't paginate them.
+func (c *PrivXClient) GetAPIClients(ctx context.Context) ([]APIClient, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	result := apiClientsResult{}
+	_, err := api.
+		URL("/local-user-store/api/v1/api-clients").
+		Get(&result)
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return result.Items, api.RateLimit(), nil
+}
+
+// IsAPIClient reports whether the given ID is the ID of an API client.
+func (c *PrivXClient) IsAPIClient(ctx context.Context, id string) (bool, error) {
+	_, err := c.getAPIClient(ctx, id)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeleteAPIClient deletes an API client.
+func (c *PrivXClient) DeleteAPIClient(ctx context.Context, id string) error {
+	_, err := c.connector(ctx).
+		URL("/local-user-store/api/v1/api-clients/%s", url.PathEscape(id)).
+		Delete()
+	return err
+}
+
+// apiClientSecretUpdate is the body of an API client update replacing its
+// secret. It carries none of the client's other secrets, which are left as
+// they are.
+type apiClientSecretUpdate struct {
+	Name   string              `json:"name"`
+	Roles  []rolestore.RoleRef `json:"roles"`
+	Secret string              `json:"secret"`
+}
+
+// SetAPIClientSecret replaces the secret of an API client. Secrets are never
+// read back, PrivX accepting the update is taken as the secret being set.
+func (c *PrivXClient) SetAPIClientSecret(ctx context.Context, id, secret string) error {
+	apiClient, err := c.getAPIClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	roles := apiClient.Roles
+	if roles == nil {
+		roles = []rolestore.RoleRef{}
+	}
+	_, err = c.connector(ctx).
+		URL("/local-user-store/api/v1/api-clients/%s", url.PathEscape(id)).
+		Put(apiClientSecretUpdate{
+			Name:   apiClient.Name,
+			Roles:  roles,
+			Secret: secret,
+		})
+	return err
+}
+
+// getAPIClient fetches a single API client. Its secrets are served along with
+// it but discarded while decoding.
+func (c *PrivXClient) getAPIClient(ctx context.Context, id string) (*APIClient, error) {
+	apiClient := &APIClient{}
+	_, err := c.connector(ctx).
+		URL("/local-user-store/api/v1/api-clients/%s", url.PathEscape(id)).
+		Get(apiClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiClient, nil
+}
@@ -0,0 +1,452 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/restapi"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/helpers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultRetry matches the privx-sdk-go client, which retries once when a
+	// request is rejected with 401 so that a refreshed access token is used.
+	defaultRetry = 2
+
+	// defaultMaxBackoffRetries is how many times a throttled (429) or failing
+	// (5xx) request is retried before its error is returned.
+	defaultMaxBackoffRetries = 5
+	initialBackoff           = time.Second
+	maxBackoff               = time.Minute
+)
+
+// contextConnector is a restapi.Connector whose requests are all bound to a
+// context. The privx-sdk-go connector builds its requests without one, so a
+// cancelled sync would otherwise keep calling PrivX until each call returned.
+type contextConnector struct {
+	ctx        context.Context
+	auth       restapi.Authorizer
+	baseURL    string
+	timeout    time.Duration
+	retry      int
+	maxBackoff int
+	http       *http.Client
+	// rateLimit is the most recent rate limit reported by PrivX for requests
+	// made through this connector.
+	rateLimit *v2.RateLimitDescription
+}
+
+func newContextConnector(
+	baseURL string,
+	auth restapi.Authorizer,
+	timeout time.Duration,
+) *contextConnector {
+	return &contextConnector{
+		ctx:        context.Background(),
+		auth:       auth,
+		baseURL:    baseURL,
+		timeout:    timeout,
+		retry:      defaultRetry,
+		maxBackoff: defaultMaxBackoffRetries,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:          http.ProxyFromEnvironment,
+				ReadBufferSize: 128 * 1024,
+				DialContext: (&net.Dialer{
+					Timeout: 10 * time.Second,
+				}).DialContext,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// WithContext returns a copy of the connector whose requests are bound to ctx.
+func (c *contextConnector) WithContext(ctx context.Context) *contextConnector {
+	connectorCopy := *c
+	connectorCopy.ctx = ctx
+	connectorCopy.rateLimit = nil
+	return &connectorCopy
+}
+
+// RateLimit returns the rate limit PrivX reported on the last response
+// received through this connector, or nil if it didn't report one.
+func (c *contextConnector) RateLimit() *v2.RateLimitDescription {
+	return c.rateLimit
+}
+
+// URL creates a request to the specified endpoint. It is either an absolute
+// URL or a path relative to the base URL.
+func (c *contextConnector) URL(templatePath string, args ...interface{}) restapi.CURL {
+	target := fmt.Sprintf(templatePath, args...)
+	if len(target) > 0 && target[0] == '/' {
+		target = c.baseURL + target
+	}
+
+	return &contextRequest{
+		connector: c,
+		url:       target,
+		header:    http.Header{},
+	}
+}
+
+// contextRequest is a restapi.CURL request builder bound to its connector's
+// context.
+type contextRequest struct {
+	connector *contextConnector
+	method    string
+	url       string
+	header    http.Header
+	payload   []byte
+	fail      error
+}
+
+// Query defines URI parameters of the request.
+func (r *contextRequest) Query(data interface{}) restapi.CURL {
+	if r.fail != nil {
+		return r
+	}
+
+	params, err := encodeValues(data)
+	if r.fail = err; err != nil {
+		return r
+	}
+	r.url = r.url + "?" + params.Encode()
+	return r
+}
+
+// Header defines a request header.
+func (r *contextRequest) Header(head, value string) restapi.CURL {
+	r.header.Add(head, value)
+	return r
+}
+
+// Status sends a GET request and discards the payload, failing unless the
+// response has one of the expected statuses.
+func (r *contextRequest) Status(status ...int) (http.Header, error) {
+	r.method = http.MethodGet
+	return r.status(status...)
+}
+
+// Get fetches content from the endpoint.
+func (r *contextRequest) Get(in interface{}) (http.Header, error) {
+	r.method = http.MethodGet
+	return r.recv(in)
+}
+
+// Put sends content to the endpoint.
+func (r *contextRequest) Put(eg interface{}, in ...interface{}) (http.Header, error) {
+	r.method = http.MethodPut
+	r.send(eg)
+
+	if len(in) > 0 {
+		return r.recv(in[0])
+	}
+
+	return r.status()
+}
+
+// Post sends content to the endpoint.
+func (r *contextRequest) Post(eg interface{}, in ...interface{}) (http.Header, error) {
+	r.method = http.MethodPost
+
+	if eg != nil {
+		r.send(eg)
+	}
+
+	if len(in) > 0 {
+		return r.recv(in[0])
+	}
+
+	return r.status()
+}
+
+// Delete removes the content behind the URL.
+func (r *contextRequest) Delete(in ...interface{}) (http.Header, error) {
+	r.method = http.MethodDelete
+
+	if len(in) > 0 {
+		return r.recv(in[0])
+	}
+
+	return r.status()
+}
+
+// Fetch receives the raw content of the endpoint.
+func (r *contextRequest) Fetch() ([]byte, error) {
+	r.method = http.MethodGet
+
+	_, body, err := r.execute()
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// Download fetches the endpoint and writes its content to filename.
+func (r *contextRequest) Download(filename string) error {
+	body, err := r.Fetch()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, body, 0600)
+}
+
+func (r *contextRequest) send(data interface{}) {
+	if r.fail != nil {
+		return
+	}
+
+	if r.header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		params, err := encodeValues(data)
+		if r.fail = err; err == nil {
+			r.payload = []byte(params.Encode())
+		}
+		return
+	}
+
+	r.header.Set("Content-Type", "application/json")
+	encoded, err := json.Marshal(data)
+	if r.fail = err; err == nil {
+		r.payload = encoded
+	}
+}
+
+func (r *contextRequest) status(status ...int) (http.Header, error) {
+	response, body, err := r.execute()
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkStatus(response, body, status...)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Header, nil
+}
+
+func (r *contextRequest) recv(data interface{}) (http.Header, error) {
+	response, body, err := r.execute()
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkStatus(response, body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Header, nil
+}
+
+// execute sends the request and returns the response along with its fully
+// read body. Like the privx-sdk-go client it retries once on 401 so that a
+// refreshed access token is used. Throttled and failing requests are retried
+// with exponential backoff, honouring Retry-After when PrivX sends it. The
+// connector's timeout, if any, applies to each attempt.
+func (r *contextRequest) execute() (*http.Response, []byte, error) {
+	if r.fail != nil {
+		return nil, nil, r.fail
+	}
+
+	unauthorized := 0
+	backoff := 0
+	for {
+		response, body, err := r.do()
+		if err != nil {
+			return nil, nil, err
+		}
+		r.recordRateLimit(response)
+
+		if response.StatusCode == http.StatusUnauthorized {
+			unauthorized++
+			if unauthorized < r.connector.retry {
+				continue
+			}
+			return nil, nil, status.Errorf(codes.Unauthenticated, "request failed after %d tries", r.connector.retry)
+		}
+
+		if !r.shouldBackoff(response) || backoff >= r.connector.maxBackoff {
+			return response, body, nil
+		}
+
+		wait, ok := retryAfter(response.Header, time.Now())
+		if !ok {
+			wait = min(initialBackoff<<backoff, maxBackoff)
+		}
+		backoff++
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.connector.ctx.Done():
+			timer.Stop()
+			return nil, nil, r.connector.ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldBackoff reports whether a response is worth retrying. Requests that
+// were throttled or refused as unavailable were not processed and are always
+// retried, other server errors only for idempotent methods.
+func (r *contextRequest) shouldBackoff(response *http.Response) bool {
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return r.method != http.MethodPost
+	default:
+		return false
+	}
+}
+
+// recordRateLimit keeps the rate limit reported by a response, if any.
+func (r *contextRequest) recordRateLimit(response *http.Response) {
+	rateLimit, err := helpers.ExtractRateLimitData(response.StatusCode, &response.Header)
+	if err != nil || rateLimit == nil {
+		return
+	}
+
+	if rateLimit.Status == v2.RateLimitDescription_STATUS_UNSPECIFIED && rateLimit.Limit == 0 {
+		return
+	}
+
+	r.connector.rateLimit = rateLimit
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an
+// HTTP date. It returns false if the header is missing or invalid.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxBackoff), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return min(max(date.Sub(now), 0), maxBackoff), true
+	}
+
+	return 0, false
+}
+
+func (r *contextRequest) do() (*http.Response, []byte, error) {
+	ctx := r.connector.ctx
+	if r.connector.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.connector.timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, r.method, r.url, bytes.NewReader(r.payload))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for head := range r.header {
+		request.Header.Set(head, r.header.Get(head))
+	}
+
+	if r.connector.auth != nil {
+		token, err := r.connector.auth.AccessToken()
+		if err != nil {
+			return nil, nil, err
+		}
+		request.Header.Set("Authorization", token)
+
+		if cookie := r.connector.auth.Cookie(); cookie != "" {
+			request.Header.Set("Cookie", cookie)
+		}
+	}
+	request.Header.Set("User-Agent", restapi.UserAgent)
+
+	response, err := r.connector.http.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, body, nil
+}
+
+// checkStatus returns an APIError built from the PrivX error response unless
+// the response has the expected status, or any non-error status if none is
+// given.
+func checkStatus(response *http.Response, body []byte, expectedStatus ...int) error {
+	if len(expectedStatus) > 0 {
+		for _, expected := range expectedStatus {
+			if response.StatusCode == expected {
+				return nil
+			}
+		}
+		return newAPIError(response, body)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return newAPIError(response, body)
+	}
+
+	return nil
+}
+
+// encodeValues flattens a struct or map into URL values, the same way the
+// privx-sdk-go client encodes queries and forms.
+func encodeValues(data interface{}) (url.Values, error) {
+	bin, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var params map[string]interface{}
+	if err = json.Unmarshal(bin, &params); err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	for key, param := range params {
+		var value string
+		switch v := param.(type) {
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			value = v
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("wrong format: %T", v)
+		}
+		values.Set(key, value)
+	}
+
+	return values, nil
+}
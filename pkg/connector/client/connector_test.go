@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextConnector(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				select {
+				case <-request.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	t.Run("should stop when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		connector := newContextConnector(server.URL, nil, 0).WithContext(ctx)
+		_, err := connector.URL("/role-store/api/v1/roles").Get(&struct{}{})
+		require.True(t, errors.Is(err, context.Canceled))
+	})
+
+	t.Run("should stop when the request deadline is exceeded", func(t *testing.T) {
+		connector := newContextConnector(server.URL, nil, 50*time.Millisecond)
+		_, err := connector.URL("/role-store/api/v1/roles").Get(&struct{}{})
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestContextConnectorBackoff(t *testing.T) {
+	t.Run("should retry throttled requests after Retry-After", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					requests++
+					if requests == 1 {
+						writer.Header().Set("Retry-After", "0")
+						writer.WriteHeader(http.StatusTooManyRequests)
+						return
+					}
+					writer.Header().Set("X-Ratelimit-Limit", "100")
+					writer.Header().Set("X-Ratelimit-Remaining", "99")
+					writer.WriteHeader(http.StatusOK)
+					_, _ = writer.Write([]byte(`{"count": 0, "items": []}`))
+				},
+			),
+		)
+		defer server.Close()
+
+		connector := newContextConnector(server.URL, nil, 0)
+		_, err := connector.URL("/role-store/api/v1/roles").Get(&struct{}{})
+		require.Nil(t, err)
+		require.Equal(t, 2, requests)
+		require.NotNil(t, connector.RateLimit())
+		require.Equal(t, int64(99), connector.RateLimit().Remaining)
+	})
+
+	t.Run("should give up after the maximum number of retries", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					requests++
+					writer.Header().Set("Retry-After", "0")
+					writer.WriteHeader(http.StatusTooManyRequests)
+				},
+			),
+		)
+		defer server.Close()
+
+		connector := newContextConnector(server.URL, nil, 0)
+		_, err := connector.URL("/role-store/api/v1/roles").Get(&struct{}{})
+		require.NotNil(t, err)
+		require.Equal(t, defaultMaxBackoffRetries+1, requests)
+		require.Equal(t, v2.RateLimitDescription_STATUS_OVERLIMIT, connector.RateLimit().Status)
+	})
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SSHcom/privx-sdk-go/restapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// APIError is a PrivX REST error response. It carries the parsed
+// restapi.ErrorResponse and maps onto a gRPC status, so that callers (and
+// baton) can tell e.g. a missing object from an auth failure or an outage.
+type APIError struct {
+	StatusCode int
+	Response   restapi.ErrorResponse
+	message    string
+}
+
+func (e *APIError) Error() string {
+	return e.message
+}
+
+// GRPCStatus implements the interface used by status.FromError and
+// status.Code, including through wrapped errors.
+func (e *APIError) GRPCStatus() *status.Status {
+	return status.New(e.Code(), e.message)
+}
+
+// Code returns the gRPC code for the error. The PrivX error_code is preferred
+// over the HTTP status because some services report e.g. a missing object as
+// a 400.
+func (e *APIError) Code() codes.Code {
+	if code, ok := errorCodes[e.Response.ErrorCode]; ok {
+		return code
+	}
+
+	switch {
+	case e.StatusCode == http.StatusBadRequest,
+		e.StatusCode == http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case e.StatusCode == http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case e.StatusCode == http.StatusForbidden:
+		return codes.PermissionDenied
+	case e.StatusCode == http.StatusNotFound:
+		return codes.NotFound
+	case e.StatusCode == http.StatusConflict,
+		e.StatusCode == http.StatusPreconditionFailed:
+		return codes.Aborted
+	case e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode == http.StatusBadGateway,
+		e.StatusCode == http.StatusServiceUnavailable,
+		e.StatusCode == http.StatusGatewayTimeout:
+		return codes.Unavailable
+	case e.StatusCode == http.StatusNotImplemented:
+		return codes.Unimplemented
+	case e.StatusCode >= http.StatusInternalServerError:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// errorCodes maps the PrivX `error_code` values onto gRPC codes.
+var errorCodes = map[string]codes.Code{
+	"NOT_FOUND":           codes.NotFound,
+	"OBJECT_NOT_FOUND":    codes.NotFound,
+	"BAD_REQUEST":         codes.InvalidArgument,
+	"INVALID_REQUEST":     codes.InvalidArgument,
+	"UNAUTHORIZED":        codes.Unauthenticated,
+	"FORBIDDEN":           codes.PermissionDenied,
+	"CONFLICT":            codes.Aborted,
+	"ALREADY_EXISTS":      codes.AlreadyExists,
+	"SERVICE_UNAVAILABLE": codes.Unavailable,
+	"INTERNAL_ERROR":      codes.Internal,
+}
+
+// newAPIError builds an APIError from a PrivX error response. The message is
+// the one restapi.ErrorFromResponse would produce, so logs stay the same.
+func newAPIError(response *http.Response, body []byte) *APIError {
+	apiError := &APIError{
+		StatusCode: response.StatusCode,
+		message:    restapi.ErrorFromResponse(response, body).Error(),
+	}
+
+	if len(body) > 0 {
+		// A body that isn't an error response still gets a code from the
+		// HTTP status.
+		_ = json.Unmarshal(body, &apiError.Response)
+	}
+
+	return apiError
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAPIError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		expected   codes.Code
+	}{
+		{"error code wins over status", http.StatusBadRequest, `{"error_code":"OBJECT_NOT_FOUND","error_message":"no such user"}`, codes.NotFound},
+		{"not found", http.StatusNotFound, ``, codes.NotFound},
+		{"forbidden", http.StatusForbidden, `{"error_code":"SOMETHING_ELSE"}`, codes.PermissionDenied},
+		{"conflict", http.StatusConflict, ``, codes.Aborted},
+		{"bad request", http.StatusBadRequest, `not json`, codes.InvalidArgument},
+		{"internal", http.StatusInternalServerError, ``, codes.Internal},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(
+				http.HandlerFunc(
+					func(writer http.ResponseWriter, request *http.Request) {
+						writer.WriteHeader(testCase.statusCode)
+						_, _ = writer.Write([]byte(testCase.body))
+					},
+				),
+			)
+			defer server.Close()
+
+			connector := newContextConnector(server.URL, nil, 0)
+			connector.maxBackoff = 0
+			_, err := connector.URL("/").Status()
+			require.NotNil(t, err)
+
+			// The code must survive wrapping.
+			wrapped := fmt.Errorf("baton-privx: %w", err)
+			require.Equal(t, testCase.expected, status.Code(wrapped))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// Host is the subset of a PrivX host-store host that the connector syncs.
+type Host struct {
+	ID                  string          `json:"id"`
+	Name                string          `json:"common_name"`
+	ExternalID          string          `json:"external_id,omitempty"`
+	InstanceID          string          `json:"instance_id,omitempty"`
+	SourceID            string          `json:"source_id,omitempty"`
+	AccessGroupID       string          `json:"access_group_id,omitempty"`
+	CloudProvider       string          `json:"cloud_provider,omitempty"`
+	CloudProviderRegion string          `json:"cloud_provider_region,omitempty"`
+	Comment             string          `json:"comment,omitempty"`
+	Disabled            string          `json:"disabled,omitempty"`
+	Deployable          bool            `json:"deployable,omitempty"`
+	Audit               bool            `json:"audit_enabled,omitempty"`
+	Tags                []string        `json:"tags,omitempty"`
+	Addresses           []string        `json:"addresses,omitempty"`
+	Principals          []HostPrincipal `json:"principals,omitempty"`
+	Status              []HostStatus    `json:"status,omitempty"`
+}
+
+// HostPrincipal is a target account on a host and the roles mapped to it.
+type HostPrincipal struct {
+	Principal      string              `json:"principal"`
+	Roles          []rolestore.RoleRef `json:"roles"`
+	Source         string              `json:"source,omitempty"`
+	UseUserAccount bool                `json:"use_user_account,omitempty"`
+}
+
+// HostStatus is a key/value deployment status entry reported for a host.
+type HostStatus struct {
+	K string `json:"k,omitempty"`
+	V string `json:"v,omitempty"`
+}
+
+type hostsResult struct {
+	Count int    `json:"count"`
+	Items []Host `json:"items"`
+}
+
+type pageParams struct {
+	Offset int `json:"offset,omitempty"`
+	Limit  int `json:"limit,omitempty"`
+}
+
+// GetHosts uses pagination to get a list of hosts from the host-store.
+func (c *PrivXClient) GetHosts(
+	ctx context.Context,
+	offset int,
+	limit int,
+) (
+	[]Host,
+	string,
+	*v2.RateLimitDescription,
+	error,
+) {
+	api := c.connector(ctx)
+	result := hostsResult{}
+	_, err := api.
+		URL("/host-store/api/v1/hosts").
+		Query(pageParams{Offset: offset, Limit: limit}).
+		Get(&result)
+	if err != nil {
+		return nil, "", api.RateLimit(), err
+	}
+
+	nextToken := getNextToken(offset, len(result.Items), limit)
+
+	return result.Items, nextToken, api.RateLimit(), nil
+}
+
+// GetHost fetches a single host, including its principals and the roles
+// mapped to each of them.
+func (c *PrivXClient) GetHost(ctx context.Context, hostId string) (*Host, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	host := &Host{}
+	_, err := api.
+		URL("/host-store/api/v1/hosts/%s", url.PathEscape(hostId)).
+		Get(host)
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return host, api.RateLimit(), nil
+}
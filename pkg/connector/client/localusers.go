@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// CreateLocalUser creates a user in the PrivX local user store and returns
+// its ID, which is also its ID in the role-store. The password may be empty
+// to create a user that can't log in with one.
+func (c *PrivXClient) CreateLocalUser(ctx context.Context, user userstore.LocalUser) (string, error) {
+	return userstore.New(c.connector(ctx)).CreateLocalUser(user)
+}
+
+// GetUser fetches a single user from the role-store.
+func (c *PrivXClient) GetUser(ctx context.Context, userId string) (*rolestore.User, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	user, err := rolestore.New(api).User(userId)
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return user, api.RateLimit(), nil
+}
+
+// GetLocalUser fetches a user from the PrivX local user store. Users of
+// directory sources aren't found there.
+func (c *PrivXClient) GetLocalUser(ctx context.Context, userId string) (*userstore.LocalUser, error) {
+	return userstore.New(c.connector(ctx)).LocalUser(userId)
+}
+
+// DeleteLocalUser deletes a user from the PrivX local user store.
+func (c *PrivXClient) DeleteLocalUser(ctx context.Context, userId string) error {
+	_, err := c.connector(ctx).
+		URL("/local-user-store/api/v1/users/%s", url.PathEscape(userId)).
+		Delete()
+	return err
+}
+
+// SetLocalUserPassword replaces the password of a local user store user.
+func (c *PrivXClient) SetLocalUserPassword(ctx context.Context, userId, password string) error {
+	return userstore.New(c.connector(ctx)).UpdateLocalUserPassword(userId, &userstore.Password{Password: password})
+}
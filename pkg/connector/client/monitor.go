@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+
+	"github.com/SSHcom/privx-sdk-go/api/monitor"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// GetAuditEvents uses pagination to get the audit events recorded by the
+// monitor-service since startTime, oldest first so that events recorded while
+// paging are appended after the current page. An empty startTime returns
+// every event.
+func (c *PrivXClient) GetAuditEvents(
+	ctx context.Context,
+	startTime string,
+	offset int,
+	limit int,
+) (
+	[]monitor.AuditEvent,
+	string,
+	*v2.RateLimitDescription,
+	error,
+) {
+	api := c.connector(ctx)
+	result, err := monitor.New(api).SearchAuditEvents(
+		offset,
+		limit,
+		"created",
+		"ASC",
+		false,
+		&monitor.AuditEventSearchObject{StartTime: startTime},
+	)
+	if err != nil {
+		return nil, "", api.RateLimit(), err
+	}
+
+	nextToken := getNextToken(offset, len(result.Items), limit)
+
+	return result.Items, nextToken, api.RateLimit(), nil
+}
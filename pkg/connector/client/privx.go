@@ -2,19 +2,67 @@ package client
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/SSHcom/privx-sdk-go/oauth"
 	"github.com/SSHcom/privx-sdk-go/restapi"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GrantTypeTimeRestricted is the grant type of role memberships that end at
+// a given time.
+const GrantTypeTimeRestricted = "TIME_RESTRICTED"
+
+// allPagesLimit is the page size used when the connector needs to read a
+// whole collection at once.
+const allPagesLimit = 100
+
+const (
+	// maxRoleUpdateAttempts is how many times a user's roles, or a role's
+	// source rules, are read and written before giving up on concurrent
+	// modifications.
+	maxRoleUpdateAttempts = 5
+	roleUpdateBackoff     = 200 * time.Millisecond
+)
+
+// ErrImplicitRoleMembership is returned when revoking a role that the user
+// holds through the role's directory source rules rather than explicitly.
+var ErrImplicitRoleMembership = status.Error(
+	codes.FailedPrecondition,
+	"role membership is implicit (granted by source rules) and can't be revoked per user",
 )
 
 type PrivXClient struct {
 	Authorizer restapi.Authorizer
-	RoleStore  rolestore.RoleStore
+	api        *contextConnector
+	// apiClientId is the ID of the API client the connector authenticates
+	// as.
+	apiClientId string
+}
+
+// Option configures optional behaviour of the PrivX client.
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	requestTimeout time.Duration
+}
+
+// WithRequestTimeout sets a deadline for each individual PrivX API call, on
+// top of any deadline carried by the caller's context. Zero disables it.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(options *clientOptions) {
+		options.requestTimeout = timeout
+	}
 }
 
 func NewPrivXClient(
@@ -24,31 +72,49 @@ func NewPrivXClient(
 	apiClientSecret string,
 	oauthClientId string,
 	oauthClientSecret string,
+	opts ...Option,
 ) (*PrivXClient, error) {
 	baseUrl = strings.Trim(baseUrl, "/")
 
+	options := &clientOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// Access tokens are fetched lazily from inside API calls, which don't
+	// pass their context down to the authorizer, so token requests are only
+	// bound by the per-call deadline.
 	authorizer := oauth.With(
-		restapi.New(
-			restapi.BaseURL(baseUrl),
-		),
+		newContextConnector(baseUrl, nil, options.requestTimeout),
 		oauth.Access(apiClientId),
 		oauth.Secret(apiClientSecret),
 		oauth.Digest(oauthClientId, oauthClientSecret),
 	)
 
-	roleStore := rolestore.New(
-		restapi.New(
-			restapi.Auth(authorizer),
-			restapi.BaseURL(baseUrl),
-		),
-	)
-
 	return &PrivXClient{
-		Authorizer: authorizer,
-		RoleStore:  *roleStore,
+		Authorizer:  authorizer,
+		api:         newContextConnector(baseUrl, authorizer, options.requestTimeout),
+		apiClientId: apiClientId,
 	}, nil
 }
 
+// IsConnectorAPIClient tells whether id is the API client the connector
+// authenticates as.
+func (c *PrivXClient) IsConnectorAPIClient(id string) bool {
+	return id == c.apiClientId
+}
+
+// connector returns a restapi.Connector whose requests are bound to ctx. It
+// also records the rate limit PrivX reports on those requests.
+func (c *PrivXClient) connector(ctx context.Context) *contextConnector {
+	return c.api.WithContext(ctx)
+}
+
+// roleStore returns a role-store client whose requests are bound to ctx.
+func (c *PrivXClient) roleStore(ctx context.Context) *rolestore.RoleStore {
+	return rolestore.New(c.connector(ctx))
+}
+
 func getNextToken(start, found, pageSize int) string {
 	if found < pageSize {
 		return ""
@@ -74,9 +140,10 @@ func (c *PrivXClient) Verify(ctx context.Context) error {
 }
 
 // GetUsers uses pagination to get a list of users from the global list. Returns
-// ([]user, string, error) tuple that represents the fetched list of users, the
-// next pagination token, and potentially any errors. The next pagination token
-// is a string so that we can use `""` as a signal that there are no more pages.
+// ([]user, string, rateLimit, error) tuple that represents the fetched list of
+// users, the next pagination token, the rate limit reported by PrivX, and
+// potentially any errors. The next pagination token is a string so that we can
+// use `""` as a signal that there are no more pages.
 func (c *PrivXClient) GetUsers(
 	ctx context.Context,
 	offset int,
@@ -84,9 +151,11 @@ func (c *PrivXClient) GetUsers(
 ) (
 	[]rolestore.User,
 	string,
+	*v2.RateLimitDescription,
 	error,
 ) {
-	privXUsers, err := c.RoleStore.SearchUsers(
+	api := c.connector(ctx)
+	privXUsers, err := rolestore.New(api).SearchUsers(
 		offset,
 		limit,
 		"",
@@ -94,12 +163,12 @@ func (c *PrivXClient) GetUsers(
 		rolestore.UserSearchObject{},
 	)
 	if err != nil {
-		return nil, "", err
+		return nil, "", api.RateLimit(), err
 	}
 
 	nextToken := getNextToken(offset, len(privXUsers), limit)
 
-	return privXUsers, nextToken, nil
+	return privXUsers, nextToken, api.RateLimit(), nil
 }
 
 func (c *PrivXClient) GetRoles(
@@ -109,21 +178,49 @@ func (c *PrivXClient) GetRoles(
 ) (
 	[]rolestore.Role,
 	string,
+	*v2.RateLimitDescription,
 	error,
 ) {
-	privXRoles, err := c.RoleStore.Roles(
+	api := c.connector(ctx)
+	privXRoles, err := rolestore.New(api).Roles(
 		offset,
 		limit,
 		"",
 		"",
 	)
 	if err != nil {
-		return nil, "", err
+		return nil, "", api.RateLimit(), err
 	}
 
 	nextToken := getNextToken(offset, len(privXRoles), limit)
 
-	return privXRoles, nextToken, nil
+	return privXRoles, nextToken, api.RateLimit(), nil
+}
+
+// GetAllRoles pages through every role configured in PrivX.
+func (c *PrivXClient) GetAllRoles(ctx context.Context) ([]rolestore.Role, *v2.RateLimitDescription, error) {
+	var allRoles []rolestore.Role
+	offset := 0
+	for {
+		privXRoles, nextToken, rateLimit, err := c.GetRoles(ctx, offset, allPagesLimit)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+		allRoles = append(allRoles, privXRoles...)
+
+		if nextToken == "" {
+			return allRoles, rateLimit, nil
+		}
+		offset += len(privXRoles)
+	}
+}
+
+// GetSources fetches every user and host directory configured in PrivX. The
+// role-store does not paginate sources, so the whole list is returned at once.
+func (c *PrivXClient) GetSources(ctx context.Context) ([]rolestore.Source, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	privXSources, err := rolestore.New(api).Sources()
+	return privXSources, api.RateLimit(), err
 }
 
 func (c *PrivXClient) GetUsersForRole(
@@ -134,9 +231,11 @@ func (c *PrivXClient) GetUsersForRole(
 ) (
 	[]rolestore.User,
 	string,
+	*v2.RateLimitDescription,
 	error,
 ) {
-	privXRoles, err := c.RoleStore.GetRoleMembers(
+	api := c.connector(ctx)
+	privXRoles, err := rolestore.New(api).GetRoleMembers(
 		roleId,
 		offset,
 		limit,
@@ -144,24 +243,400 @@ func (c *PrivXClient) GetUsersForRole(
 		"",
 	)
 	if err != nil {
-		return nil, "", err
+		return nil, "", api.RateLimit(), err
 	}
 
 	nextToken := getNextToken(offset, len(privXRoles), limit)
 
-	return privXRoles, nextToken, nil
+	return privXRoles, nextToken, api.RateLimit(), nil
+}
+
+// versionedRole is a role along with its last modification time, which the
+// role-store reports but rolestore.Role doesn't model.
+type versionedRole struct {
+	rolestore.Role
+	Updated string `json:"updated,omitempty"`
+}
+
+// GetRole fetches a single role.
+func (c *PrivXClient) GetRole(ctx context.Context, roleId string) (*rolestore.Role, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	role, err := rolestore.New(api).Role(roleId)
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return role, api.RateLimit(), nil
+}
+
+// roleMutation modifies a role in place. Like rolesMutation it reports false
+// if the role already is as wanted.
+type roleMutation func(role *rolestore.Role) bool
+
+// updateRole applies mutate to a role. A role can only be replaced as a whole,
+// so this guards against concurrent updates the same way updateUserRoles
+// does, using the role's ETag or updated timestamp.
+func (c *PrivXClient) updateRole(ctx context.Context, roleId string, mutate roleMutation) error {
+	return retryOnConflict(ctx, "role "+roleId, func() (bool, error) {
+		role, etag, err := c.getVersionedRole(ctx, roleId)
+		if err != nil {
+			return false, err
+		}
+
+		if !mutate(&role.Role) {
+			return true, nil
+		}
+
+		current, currentEtag, err := c.getVersionedRole(ctx, roleId)
+		if err != nil {
+			return false, err
+		}
+		if roleVersion(current, currentEtag) != roleVersion(role, etag) {
+			return false, nil
+		}
+
+		request := c.connector(ctx).URL("/role-store/api/v1/roles/%s", url.PathEscape(roleId))
+		if etag != "" {
+			request = request.Header("If-Match", etag)
+		}
+		_, err = request.Put(&role.Role)
+		if err != nil {
+			return false, err
+		}
+
+		written, _, err := c.getVersionedRole(ctx, roleId)
+		if err != nil {
+			return false, err
+		}
+
+		return !mutate(&written.Role), nil
+	})
+}
+
+// getVersionedRole fetches a role along with its ETag, which is empty if
+// PrivX didn't send one.
+func (c *PrivXClient) getVersionedRole(ctx context.Context, roleId string) (*versionedRole, string, error) {
+	role := &versionedRole{}
+	header, err := c.connector(ctx).
+		URL("/role-store/api/v1/roles/%s", url.PathEscape(roleId)).
+		Get(role)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return role, header.Get("ETag"), nil
+}
+
+// roleVersion returns a value that changes whenever the role is modified.
+func roleVersion(role *versionedRole, etag string) string {
+	if etag != "" {
+		return etag
+	}
+	return role.Updated
+}
+
+// GetUserRoles fetches the role memberships of a user, explicit and implicit.
+func (c *PrivXClient) GetUserRoles(ctx context.Context, userId string) ([]rolestore.Role, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	roles, err := rolestore.New(api).UserRoles(userId)
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return roles, api.RateLimit(), nil
+}
+
+// GetUserRole returns the user's membership entry for the given role, or nil
+// if the user doesn't hold it.
+func (c *PrivXClient) GetUserRole(ctx context.Context, userId, roleId string) (*rolestore.Role, error) {
+	roles, err := c.roleStore(ctx).UserRoles(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		if roles[i].ID == roleId {
+			return &roles[i], nil
+		}
+	}
+
+	return nil, nil
 }
 
-// GrantRole fetches the list of roles for a given user and appends the
-// specified role to that list. NOTE: the fetch and put are _not_ atomic and
-// can cause race conditions.
+// GrantRole adds an explicit grant of the specified role to a user, unless
+// they already hold it explicitly. A membership that is only implicit is
+// made explicit too, so that it outlives changes to the role's source rules.
+// The update is conflict-safe, see updateUserRoles.
 func (c *PrivXClient) GrantRole(ctx context.Context, userId, roleId string) error {
-	return c.RoleStore.GrantUserRole(userId, roleId)
+	// Fail early, the role-store accepts unknown role IDs in a role list.
+	_, err := c.roleStore(ctx).Role(roleId)
+	if err != nil {
+		return err
+	}
+
+	return c.updateUserRoles(ctx, userId, func(roles []rolestore.Role) ([]rolestore.Role, bool, error) {
+		for i := range roles {
+			if roles[i].ID != roleId {
+				continue
+			}
+			if roles[i].Explicit {
+				// Already granted.
+				return roles, false, nil
+			}
+
+			newRoles := slices.Clone(roles)
+			newRoles[i].Explicit = true
+			return newRoles, true, nil
+		}
+
+		return append(roles, rolestore.Role{ID: roleId, Explicit: true}), true, nil
+	})
 }
 
-// RevokeRole fetches the list of roles for a given user and removes the
-// specified role from that list. NOTE: the fetch and put are _not_ atomic and
-// can cause race conditions.
+// RevokeRole removes the specified role from a user. Memberships granted
+// implicitly through the role's source rules can't be removed per user, so
+// ErrImplicitRoleMembership is returned for them (after dropping any explicit
+// grant of the same role). The update is conflict-safe, see updateUserRoles.
 func (c *PrivXClient) RevokeRole(ctx context.Context, userId, roleId string) error {
-	return c.RoleStore.RevokeUserRole(userId, roleId)
+	implicit := false
+	err := c.updateUserRoles(ctx, userId, func(roles []rolestore.Role) ([]rolestore.Role, bool, error) {
+		var membership *rolestore.Role
+		newRoles := make([]rolestore.Role, 0, len(roles))
+		for i := range roles {
+			if roles[i].ID == roleId {
+				membership = &roles[i]
+				continue
+			}
+			newRoles = append(newRoles, roles[i])
+		}
+
+		if membership == nil {
+			// User does not have the specified role.
+			implicit = false
+			return roles, false, nil
+		}
+
+		implicit = membership.Implicit
+		if membership.Explicit || !membership.Implicit {
+			return newRoles, true, nil
+		}
+
+		return roles, false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if implicit {
+		return fmt.Errorf("%w: user %s, role %s", ErrImplicitRoleMembership, userId, roleId)
+	}
+
+	return nil
+}
+
+// RevokeExplicitRoles removes every role granted to a user explicitly. Roles
+// the user holds implicitly through source rules are left to the directory.
+// The update is conflict-safe, see updateUserRoles.
+func (c *PrivXClient) RevokeExplicitRoles(ctx context.Context, userId string) error {
+	return c.updateUserRoles(ctx, userId, func(roles []rolestore.Role) ([]rolestore.Role, bool, error) {
+		newRoles := make([]rolestore.Role, 0, len(roles))
+		for _, role := range roles {
+			if !role.Explicit {
+				newRoles = append(newRoles, role)
+			}
+		}
+
+		return newRoles, len(newRoles) != len(roles), nil
+	})
+}
+
+// GrantRoleUntil grants the specified role to a user with a TIME_RESTRICTED
+// validity window. An existing grant of the same role is replaced so that the
+// new window applies. The update is conflict-safe, see updateUserRoles.
+func (c *PrivXClient) GrantRoleUntil(
+	ctx context.Context,
+	userId string,
+	roleId string,
+	start time.Time,
+	end time.Time,
+) error {
+	grantStart := start.UTC().Format(time.RFC3339)
+	grantEnd := end.UTC().Format(time.RFC3339)
+
+	return c.updateUserRoles(ctx, userId, func(roles []rolestore.Role) ([]rolestore.Role, bool, error) {
+		newRoles := make([]rolestore.Role, 0, len(roles)+1)
+		for _, role := range roles {
+			if role.ID != roleId {
+				newRoles = append(newRoles, role)
+				continue
+			}
+
+			if role.Explicit && role.GrantType == GrantTypeTimeRestricted && sameTime(role.GrantEnd, grantEnd) {
+				// Already granted with this window. The start is ignored
+				// because retries compute a new one.
+				return roles, false, nil
+			}
+		}
+
+		newRoles = append(newRoles, rolestore.Role{
+			ID:         roleId,
+			Explicit:   true,
+			GrantType:  GrantTypeTimeRestricted,
+			GrantStart: grantStart,
+			GrantEnd:   grantEnd,
+			GrantValidityPeriods: []rolestore.ValidityPeriod{
+				{
+					GrantStart: grantStart,
+					GrantEnd:   grantEnd,
+				},
+			},
+		})
+
+		return newRoles, true, nil
+	})
+}
+
+// sameTime reports whether two RFC3339 timestamps denote the same instant.
+func sameTime(a, b string) bool {
+	timeA, errA := time.Parse(time.RFC3339Nano, a)
+	timeB, errB := time.Parse(time.RFC3339Nano, b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return timeA.Equal(timeB)
+}
+
+// rolesMutation computes a user's new role list from their current one. It
+// reports false if the roles already are as wanted, which must hold once its
+// own result has been written, so that it can also verify the write.
+type rolesMutation func(roles []rolestore.Role) ([]rolestore.Role, bool, error)
+
+// updateUserRoles applies mutate to the roles of a user. The role-store only
+// allows replacing the whole role list, so concurrent updates could silently
+// drop each other's roles. To prevent that, the user's version (its ETag, or
+// its updated timestamp if PrivX doesn't send one) is read around the roles
+// and the write is only made if it didn't change in between. The write is
+// conditional on the ETag when there is one, and the roles are read again
+// afterwards to verify that the change stuck. Any conflict restarts from a
+// fresh read, see retryOnConflict.
+func (c *PrivXClient) updateUserRoles(ctx context.Context, userId string, mutate rolesMutation) error {
+	return retryOnConflict(ctx, "user "+userId, func() (bool, error) {
+		return c.tryUpdateUserRoles(ctx, userId, mutate)
+	})
+}
+
+// retryOnConflict calls try until it reports that it is done, backing off
+// between attempts. An attempt that is not done, or that failed with
+// codes.Aborted, was defeated by a concurrent modification of subject. It
+// gives up after maxRoleUpdateAttempts attempts.
+func retryOnConflict(ctx context.Context, subject string, try func() (bool, error)) error {
+	logger := ctxzap.Extract(ctx)
+
+	for attempt := 0; attempt < maxRoleUpdateAttempts; attempt++ {
+		if attempt > 0 {
+			logger.Debug(
+				"baton-privx: modified concurrently, retrying",
+				zap.String("subject", subject),
+				zap.Int("attempt", attempt),
+			)
+
+			timer := time.NewTimer(time.Duration(attempt) * roleUpdateBackoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		done, err := try()
+		if status.Code(err) == codes.Aborted {
+			continue
+		}
+		if err != nil || done {
+			return err
+		}
+	}
+
+	return status.Errorf(
+		codes.Aborted,
+		"%s kept being modified concurrently, gave up after %d attempts",
+		subject,
+		maxRoleUpdateAttempts,
+	)
+}
+
+// tryUpdateUserRoles makes a single attempt at updateUserRoles. It returns
+// false if the roles were modified concurrently and the update must be retried.
+func (c *PrivXClient) tryUpdateUserRoles(ctx context.Context, userId string, mutate rolesMutation) (bool, error) {
+	version, etag, err := c.userVersion(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	roles, err := c.roleStore(ctx).UserRoles(userId)
+	if err != nil {
+		return false, err
+	}
+
+	newRoles, changed, err := mutate(roles)
+	if err != nil || !changed {
+		return true, err
+	}
+
+	currentVersion, _, err := c.userVersion(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	if currentVersion != version {
+		return false, nil
+	}
+
+	err = c.setUserRoles(ctx, userId, newRoles, etag)
+	if err != nil {
+		return false, err
+	}
+
+	roles, err = c.roleStore(ctx).UserRoles(userId)
+	if err != nil {
+		return false, err
+	}
+
+	_, changed, err = mutate(roles)
+	if err != nil {
+		return false, err
+	}
+
+	return !changed, nil
+}
+
+// userVersion returns a value that changes whenever a user is modified,
+// along with the user's ETag, which is empty if PrivX didn't send one.
+func (c *PrivXClient) userVersion(ctx context.Context, userId string) (string, string, error) {
+	user := rolestore.User{}
+	header, err := c.connector(ctx).
+		URL("/role-store/api/v1/users/%s", url.PathEscape(userId)).
+		Get(&user)
+	if err != nil {
+		return "", "", err
+	}
+
+	etag := header.Get("ETag")
+	if etag != "" {
+		return etag, etag, nil
+	}
+
+	return user.Updated, "", nil
+}
+
+// setUserRoles replaces the full list of roles held by a user. If etag is
+// set the write only succeeds if the user still has that ETag.
+func (c *PrivXClient) setUserRoles(ctx context.Context, userId string, roles []rolestore.Role, etag string) error {
+	request := c.connector(ctx).URL("/role-store/api/v1/users/%s/roles", url.PathEscape(userId))
+	if etag != "" {
+		request = request.Header("If-Match", etag)
+	}
+
+	_, err := request.Put(roles)
+	return err
 }
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserRoles serves a single user's roles, bumping the user's updated
+// timestamp on every write.
+type fakeUserRoles struct {
+	mutex   sync.Mutex
+	version int
+	roles   []rolestore.Role
+	puts    int
+	// onRolesRead, if set, runs after the roles are read and may modify them
+	// like a concurrent writer would.
+	onRolesRead func(f *fakeUserRoles)
+}
+
+func (f *fakeUserRoles) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case request.URL.Path == "/role-store/api/v1/users/user-1":
+		_ = json.NewEncoder(writer).Encode(rolestore.User{
+			ID:      "user-1",
+			Updated: strconv.Itoa(f.version),
+		})
+	case request.URL.Path == "/role-store/api/v1/users/user-1/roles" && request.Method == http.MethodGet:
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+			"count": len(f.roles),
+			"items": f.roles,
+		})
+		if f.onRolesRead != nil {
+			onRolesRead := f.onRolesRead
+			f.onRolesRead = nil
+			onRolesRead(f)
+		}
+	case request.URL.Path == "/role-store/api/v1/users/user-1/roles" && request.Method == http.MethodPut:
+		f.puts++
+		f.version++
+		_ = json.NewDecoder(request.Body).Decode(&f.roles)
+	case len(request.URL.Path) > len("/role-store/api/v1/roles/"):
+		_ = json.NewEncoder(writer).Encode(rolestore.Role{ID: request.URL.Path[len("/role-store/api/v1/roles/"):]})
+	default:
+		writer.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeUserRoles) roleIds() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	ids := make([]string, 0, len(f.roles))
+	for _, role := range f.roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+func TestUpdateUserRoles(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should keep roles granted concurrently", func(t *testing.T) {
+		fake := &fakeUserRoles{
+			roles: []rolestore.Role{{ID: "role-a", Explicit: true}},
+			onRolesRead: func(f *fakeUserRoles) {
+				f.roles = append(f.roles, rolestore.Role{ID: "role-b", Explicit: true})
+				f.version++
+			},
+		}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.GrantRole(ctx, "user-1", "role-c")
+		require.Nil(t, err)
+
+		require.ElementsMatch(t, []string{"role-a", "role-b", "role-c"}, fake.roleIds())
+		require.Equal(t, 1, fake.puts)
+	})
+
+	t.Run("should not write when the role is already revoked", func(t *testing.T) {
+		fake := &fakeUserRoles{
+			roles: []rolestore.Role{{ID: "role-a", Explicit: true}},
+		}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.RevokeRole(ctx, "user-1", "role-b")
+		require.Nil(t, err)
+		require.Equal(t, 0, fake.puts)
+	})
+
+	t.Run("should revoke only the requested role", func(t *testing.T) {
+		fake := &fakeUserRoles{
+			roles: []rolestore.Role{
+				{ID: "role-a", Explicit: true},
+				{ID: "role-b", Explicit: true},
+			},
+		}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.RevokeRole(ctx, "user-1", "role-b")
+		require.Nil(t, err)
+		require.Equal(t, []string{"role-a"}, fake.roleIds())
+	})
+}
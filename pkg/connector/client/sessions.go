@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/SSHcom/privx-sdk-go/api/auth"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// GetSessions uses pagination to get the sessions kept by the auth service,
+// oldest first. Sessions created while paging are then found on the pages
+// still to read, instead of shifting the ones already read.
+func (c *PrivXClient) GetSessions(
+	ctx context.Context,
+	offset int,
+	limit int,
+) (
+	[]auth.Session,
+	string,
+	*v2.RateLimitDescription,
+	error,
+) {
+	api := c.connector(ctx)
+	sessions, err := auth.New(api).SearchSessions(
+		offset,
+		limit,
+		"created",
+		"ASC",
+		&auth.SearchParams{},
+	)
+	if err != nil {
+		return nil, "", api.RateLimit(), err
+	}
+
+	nextToken := getNextToken(offset, len(sessions.Items), limit)
+
+	return sessions.Items, nextToken, api.RateLimit(), nil
+}
+
+// TerminateUserSessions ends every PrivX session of a user, so that access
+// that was revoked can't keep being used.
+func (c *PrivXClient) TerminateUserSessions(ctx context.Context, userId string) error {
+	_, err := c.connector(ctx).
+		URL("/auth/api/v1/sessionstorage/users/%s/sessions/terminate", url.PathEscape(userId)).
+		Post(nil)
+	return err
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// SourceRuleTypeGroup is a source rule node combining its child rules
+	// according to its match (ANY or ALL).
+	SourceRuleTypeGroup = "GROUP"
+	// SourceRuleTypeRule is a source rule leaf matching the users of a
+	// source, optionally narrowed down by a search string (e.g. a directory
+	// group filter).
+	SourceRuleTypeRule = "RULE"
+	SourceRuleMatchAny = "ANY"
+	SourceRuleMatchAll = "ALL"
+)
+
+// SourceRuleLeaves returns the RULE leaves of a role's source rule tree, in
+// depth first order.
+func SourceRuleLeaves(rule rolestore.SourceRule) []rolestore.SourceRule {
+	if rule.Type == SourceRuleTypeRule {
+		return []rolestore.SourceRule{rule}
+	}
+
+	var leaves []rolestore.SourceRule
+	for _, child := range rule.Rules {
+		leaves = append(leaves, SourceRuleLeaves(child)...)
+	}
+	return leaves
+}
+
+// SourceRuleGrantedLeaves returns the RULE leaves of a role's source rule tree
+// that each grant the role on their own, i.e. that are only combined with
+// others by ANY, in depth first order. Leaves below an ALL group with more
+// than one child only grant the role together with their siblings.
+func SourceRuleGrantedLeaves(rule rolestore.SourceRule) []rolestore.SourceRule {
+	if rule.Type == SourceRuleTypeRule {
+		return []rolestore.SourceRule{rule}
+	}
+	if rule.Match != SourceRuleMatchAny && len(rule.Rules) > 1 {
+		return nil
+	}
+
+	var leaves []rolestore.SourceRule
+	for _, child := range rule.Rules {
+		leaves = append(leaves, SourceRuleGrantedLeaves(child)...)
+	}
+	return leaves
+}
+
+// AddSourceRule maps the users of a source matching searchString, or all of
+// them if it is empty, to a role by adding a RULE clause to the role's source
+// rules. Nothing is written if the role already has that exact clause on its
+// own, rather than only combined with others by ALL.
+func (c *PrivXClient) AddSourceRule(ctx context.Context, roleId, sourceId, searchString string) error {
+	return c.updateSourceRules(ctx, roleId, func(rule rolestore.SourceRule) (rolestore.SourceRule, bool) {
+		for _, leaf := range SourceRuleGrantedLeaves(rule) {
+			if leaf.Source == sourceId && leaf.Pattern == searchString {
+				return rule, false
+			}
+		}
+
+		if rule.Type == "" {
+			rule = rolestore.SourceRuleNone()
+		}
+		if rule.Type != SourceRuleTypeGroup || rule.Match != SourceRuleMatchAny {
+			// Keep the existing tree intact as an alternative to the new
+			// clause.
+			rule = rolestore.SourceRule{
+				Type:  SourceRuleTypeGroup,
+				Match: SourceRuleMatchAny,
+				Rules: []rolestore.SourceRule{rule},
+			}
+		}
+
+		rule.Rules = append(rule.Rules, rolestore.SourceRule{
+			Type:    SourceRuleTypeRule,
+			Source:  sourceId,
+			Pattern: searchString,
+			Rules:   []rolestore.SourceRule{},
+		})
+		return rule, true
+	})
+}
+
+// RemoveSourceRules removes the RULE clauses mapping the users of a source
+// matching searchString to a role, an empty searchString standing for all the
+// users of the source. Groups left empty below the top of the tree are pruned.
+//
+// Removing a clause combined with others by ALL would widen the role to the
+// users matching the remaining clauses, so that is refused with a
+// FailedPrecondition error and must be done in PrivX.
+func (c *PrivXClient) RemoveSourceRules(ctx context.Context, roleId, sourceId, searchString string) error {
+	matches := func(leaf rolestore.SourceRule) bool {
+		return leaf.Source == sourceId && leaf.Pattern == searchString
+	}
+
+	var refused error
+	err := c.updateSourceRules(ctx, roleId, func(rule rolestore.SourceRule) (rolestore.SourceRule, bool) {
+		if rule.Type == SourceRuleTypeRule {
+			if matches(rule) {
+				return rolestore.SourceRuleNone(), true
+			}
+			return rule, false
+		}
+
+		newRule, changed, err := removeSourceRules(rule, matches)
+		if err != nil {
+			refused = err
+			return rule, false
+		}
+		if changed && len(newRule.Rules) == 0 {
+			// An empty ALL group could match everyone, an empty ANY group
+			// matches no one.
+			newRule = rolestore.SourceRuleNone()
+		}
+		return newRule, changed
+	})
+	if refused != nil {
+		return status.Errorf(codes.FailedPrecondition, "role %s: %s", roleId, refused)
+	}
+	return err
+}
+
+// removeSourceRules removes the leaves for which matches returns true from the
+// children of a GROUP rule, recursively. Removing a child from an ANY group
+// only narrows it, but removing one from an ALL group widens it unless no
+// other child is left, in which case the emptied group is removed from its
+// own parent in turn.
+func removeSourceRules(
+	rule rolestore.SourceRule,
+	matches func(leaf rolestore.SourceRule) bool,
+) (rolestore.SourceRule, bool, error) {
+	changed := false
+	children := make([]rolestore.SourceRule, 0, len(rule.Rules))
+	for _, child := range rule.Rules {
+		if child.Type == SourceRuleTypeRule {
+			if matches(child) {
+				changed = true
+				continue
+			}
+			children = append(children, child)
+			continue
+		}
+
+		newChild, childChanged, err := removeSourceRules(child, matches)
+		if err != nil {
+			return rule, false, err
+		}
+		changed = changed || childChanged
+		if childChanged && len(newChild.Rules) == 0 {
+			continue
+		}
+		children = append(children, newChild)
+	}
+
+	if changed && rule.Match != SourceRuleMatchAny && len(children) > 0 {
+		return rule, false, errors.New("the clause is combined with others by ALL, removing it would widen access")
+	}
+
+	rule.Rules = children
+	return rule, changed, nil
+}
+
+// updateSourceRules applies mutate to the source rules of a role, see
+// updateRole.
+func (c *PrivXClient) updateSourceRules(ctx context.Context, roleId string, mutate sourceRuleMutation) error {
+	return c.updateRole(ctx, roleId, func(role *rolestore.Role) bool {
+		newRule, changed := mutate(role.SourceRule)
+		role.SourceRule = newRule
+		return changed
+	})
+}
+
+// sourceRuleMutation computes a role's new source rules from its current ones.
+// Like rolesMutation it reports false if the rules already are as wanted.
+type sourceRuleMutation func(rule rolestore.SourceRule) (rolestore.SourceRule, bool)
+
+// GetSourceRuleMembers returns the users matched by a single source rule
+// clause, i.e. the members of the directory group it selects. The role-store
+// evaluates a role definition holding only that clause.
+func (c *PrivXClient) GetSourceRuleMembers(
+	ctx context.Context,
+	sourceId string,
+	searchString string,
+) ([]rolestore.User, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	members, err := rolestore.New(api).EvaluateRole(&rolestore.Role{
+		SourceRule: rolestore.SourceRule{
+			Type:  SourceRuleTypeGroup,
+			Match: SourceRuleMatchAny,
+			Rules: []rolestore.SourceRule{
+				{
+					Type:    SourceRuleTypeRule,
+					Source:  sourceId,
+					Pattern: searchString,
+					Rules:   []rolestore.SourceRule{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return members, api.RateLimit(), nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeRole serves a single role, bumping its updated timestamp on every write.
+type fakeRole struct {
+	mutex   sync.Mutex
+	version int
+	role    rolestore.Role
+	puts    int
+}
+
+func (f *fakeRole) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if request.URL.Path != "/role-store/api/v1/roles/role-1" {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		_ = json.NewEncoder(writer).Encode(versionedRole{
+			Role:    f.role,
+			Updated: strconv.Itoa(f.version),
+		})
+	case http.MethodPut:
+		f.puts++
+		f.version++
+		_ = json.NewDecoder(request.Body).Decode(&f.role)
+	}
+}
+
+func TestSourceRules(t *testing.T) {
+	ctx := context.Background()
+	existing := rolestore.SourceRule{
+		Type:  SourceRuleTypeGroup,
+		Match: SourceRuleMatchAny,
+		Rules: []rolestore.SourceRule{
+			{Type: SourceRuleTypeRule, Source: "source-a", Pattern: "(memberOf=admins)"},
+			{
+				Type:  SourceRuleTypeGroup,
+				Match: "ALL",
+				Rules: []rolestore.SourceRule{
+					{Type: SourceRuleTypeRule, Source: "source-b", Pattern: "(memberOf=ops)"},
+				},
+			},
+		},
+	}
+
+	t.Run("should add a clause once", func(t *testing.T) {
+		fake := &fakeRole{role: rolestore.Role{ID: "role-1", SourceRule: existing}}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.AddSourceRule(ctx, "role-1", "source-c", "")
+		require.Nil(t, err)
+		err = privXClient.AddSourceRule(ctx, "role-1", "source-c", "")
+		require.Nil(t, err)
+
+		require.Equal(t, 1, fake.puts)
+		leaves := SourceRuleLeaves(fake.role.SourceRule)
+		require.Len(t, leaves, 3)
+		require.Equal(t, "source-c", leaves[2].Source)
+	})
+
+	t.Run("should add a clause that only exists combined by ALL", func(t *testing.T) {
+		combined := rolestore.SourceRule{
+			Type:  SourceRuleTypeGroup,
+			Match: SourceRuleMatchAny,
+			Rules: []rolestore.SourceRule{
+				{
+					Type:  SourceRuleTypeGroup,
+					Match: SourceRuleMatchAll,
+					Rules: []rolestore.SourceRule{
+						{Type: SourceRuleTypeRule, Source: "source-b", Pattern: "(memberOf=ops)"},
+						{Type: SourceRuleTypeRule, Source: "source-b", Pattern: "(memberOf=oncall)"},
+					},
+				},
+			},
+		}
+		fake := &fakeRole{role: rolestore.Role{ID: "role-1", SourceRule: combined}}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.AddSourceRule(ctx, "role-1", "source-b", "(memberOf=ops)")
+		require.Nil(t, err)
+
+		require.Equal(t, 1, fake.puts)
+		leaves := SourceRuleGrantedLeaves(fake.role.SourceRule)
+		require.Len(t, leaves, 1)
+		require.Equal(t, "(memberOf=ops)", leaves[0].Pattern)
+	})
+
+	t.Run("should remove nested clauses and prune their group", func(t *testing.T) {
+		fake := &fakeRole{role: rolestore.Role{ID: "role-1", SourceRule: existing}}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.RemoveSourceRules(ctx, "role-1", "source-b", "(memberOf=ops)")
+		require.Nil(t, err)
+
+		require.Len(t, fake.role.SourceRule.Rules, 1)
+		require.Equal(t, "source-a", fake.role.SourceRule.Rules[0].Source)
+	})
+
+	t.Run("should refuse to remove a clause combined with others by ALL", func(t *testing.T) {
+		both := rolestore.SourceRule{
+			Type:  SourceRuleTypeGroup,
+			Match: SourceRuleMatchAny,
+			Rules: []rolestore.SourceRule{
+				{
+					Type:  SourceRuleTypeGroup,
+					Match: SourceRuleMatchAll,
+					Rules: []rolestore.SourceRule{
+						{Type: SourceRuleTypeRule, Source: "source-b", Pattern: "(memberOf=ops)"},
+						{Type: SourceRuleTypeRule, Source: "source-b", Pattern: "(memberOf=oncall)"},
+					},
+				},
+			},
+		}
+		fake := &fakeRole{role: rolestore.Role{ID: "role-1", SourceRule: both}}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.RemoveSourceRules(ctx, "role-1", "source-b", "(memberOf=ops)")
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.Equal(t, 0, fake.puts)
+		require.Len(t, SourceRuleLeaves(fake.role.SourceRule), 2)
+	})
+
+	t.Run("should not leave an empty ALL group at the top", func(t *testing.T) {
+		single := rolestore.SourceRule{
+			Type:  SourceRuleTypeGroup,
+			Match: SourceRuleMatchAll,
+			Rules: []rolestore.SourceRule{
+				{Type: SourceRuleTypeRule, Source: "source-b", Pattern: "(memberOf=ops)"},
+			},
+		}
+		fake := &fakeRole{role: rolestore.Role{ID: "role-1", SourceRule: single}}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		privXClient := &PrivXClient{api: newContextConnector(server.URL, nil, 0)}
+		err := privXClient.RemoveSourceRules(ctx, "role-1", "source-b", "(memberOf=ops)")
+		require.Nil(t, err)
+		require.Equal(t, SourceRuleMatchAny, fake.role.SourceRule.Match)
+		require.Len(t, fake.role.SourceRule.Rules, 0)
+	})
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	SecretAccessRead  = "read"
+	SecretAccessWrite = "write"
+)
+
+// Secret is the metadata of a PrivX Vault secret. Unlike vault.Secret it never
+// holds the secret's value, only the names of its top-level fields.
+type Secret struct {
+	Name       string              `json:"name"`
+	OwnerID    string              `json:"owner_id,omitempty"`
+	Author     string              `json:"author,omitempty"`
+	UpdatedBy  string              `json:"updated_by,omitempty"`
+	Created    string              `json:"created,omitempty"`
+	Updated    string              `json:"updated,omitempty"`
+	ReadRoles  []rolestore.RoleRef `json:"read_roles,omitempty"`
+	WriteRoles []rolestore.RoleRef `json:"write_roles,omitempty"`
+	DataKeys   SecretDataKeys      `json:"data,omitempty"`
+}
+
+// SecretDataKeys are the names of the top-level fields of a secret's value.
+// The values themselves are discarded while decoding.
+type SecretDataKeys []string
+
+func (k *SecretDataKeys) UnmarshalJSON(data []byte) error {
+	*k = nil
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		// Not an object, there are no field names to report.
+		return nil
+	}
+
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key, ok := token.(string); ok {
+			*k = append(*k, key)
+		}
+
+		var skip json.RawMessage
+		if err = decoder.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	slices.Sort(*k)
+
+	return nil
+}
+
+// RoleIDs returns the IDs of the roles with the given access to the secret.
+func (s *Secret) RoleIDs(access string) []string {
+	roles := s.ReadRoles
+	if access == SecretAccessWrite {
+		roles = s.WriteRoles
+	}
+
+	ids := make([]string, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+type secretsResult struct {
+	Count int      `json:"count"`
+	Items []Secret `json:"items"`
+}
+
+// GetSecrets uses pagination to get a list of the secrets stored in the PrivX
+// Vault that the API client can see.
+func (c *PrivXClient) GetSecrets(
+	ctx context.Context,
+	offset int,
+	limit int,
+) (
+	[]Secret,
+	string,
+	*v2.RateLimitDescription,
+	error,
+) {
+	api := c.connector(ctx)
+	result := secretsResult{}
+	_, err := api.
+		URL("/vault/api/v1/secrets").
+		Query(pageParams{Offset: offset, Limit: limit}).
+		Get(&result)
+	if err != nil {
+		return nil, "", api.RateLimit(), err
+	}
+
+	nextToken := getNextToken(offset, len(result.Items), limit)
+
+	return result.Items, nextToken, api.RateLimit(), nil
+}
+
+// GetSecret fetches the metadata of a single secret.
+func (c *PrivXClient) GetSecret(ctx context.Context, name string) (*Secret, *v2.RateLimitDescription, error) {
+	api := c.connector(ctx)
+	secret := &Secret{}
+	_, err := api.
+		URL("/vault/api/v1/metadata/secrets/%s", url.PathEscape(name)).
+		Get(secret)
+	if err != nil {
+		return nil, api.RateLimit(), err
+	}
+
+	return secret, api.RateLimit(), nil
+}
+
+// secretUpdate is the body of a secret update. The value is passed through
+// as read, without being decoded.
+type secretUpdate struct {
+	Data       json.RawMessage     `json:"data"`
+	ReadRoles  []rolestore.RoleRef `json:"read_roles"`
+	WriteRoles []rolestore.RoleRef `json:"write_roles"`
+}
+
+// GrantSecretAccess gives a role read or write access to a secret.
+func (c *PrivXClient) GrantSecretAccess(ctx context.Context, name, roleId, access string) error {
+	return c.updateSecretRoles(ctx, name, access, func(roleIds []string) ([]string, bool) {
+		if slices.Contains(roleIds, roleId) {
+			return roleIds, false
+		}
+		return append(roleIds, roleId), true
+	})
+}
+
+// RevokeSecretAccess removes the read or write access of a role to a secret.
+func (c *PrivXClient) RevokeSecretAccess(ctx context.Context, name, roleId, access string) error {
+	return c.updateSecretRoles(ctx, name, access, func(roleIds []string) ([]string, bool) {
+		if !slices.Contains(roleIds, roleId) {
+			return roleIds, false
+		}
+		return slices.DeleteFunc(roleIds, func(id string) bool { return id == roleId }), true
+	})
+}
+
+// updateSecretRoles applies mutate to the roles with the given access to a
+// secret. The vault has no endpoint updating only a secret's roles, it only
+// allows replacing a secret as a whole, value included. So the API client
+// needs read access to the secret's value, which is written back as read and
+// never decoded. The write is conditional on the secret's ETag, so a secret
+// changed since it was read, e.g. rotated, is read again instead of being
+// reverted. Without an ETag there is no safe write and the update is refused.
+func (c *PrivXClient) updateSecretRoles(
+	ctx context.Context,
+	name string,
+	access string,
+	mutate func(roleIds []string) ([]string, bool),
+) error {
+	return retryOnConflict(ctx, "secret "+name, func() (bool, error) {
+		secret, data, etag, err := c.getSecretForUpdate(ctx, name)
+		if err != nil {
+			return false, err
+		}
+
+		roleIds, changed := mutate(secret.RoleIDs(access))
+		if !changed {
+			return true, nil
+		}
+
+		if etag == "" {
+			return false, status.Errorf(
+				codes.FailedPrecondition,
+				"PrivX sent no ETag for secret %s, its roles can't be changed without risking to overwrite its value",
+				name,
+			)
+		}
+
+		update := secretUpdate{
+			Data:       data,
+			ReadRoles:  secret.ReadRoles,
+			WriteRoles: secret.WriteRoles,
+		}
+		if access == SecretAccessWrite {
+			update.WriteRoles = roleRefs(roleIds)
+		} else {
+			update.ReadRoles = roleRefs(roleIds)
+		}
+
+		_, err = c.connector(ctx).
+			URL("/vault/api/v1/secrets/%s", url.PathEscape(name)).
+			Header("If-Match", etag).
+			Put(update)
+		if err != nil {
+			return false, err
+		}
+
+		written, _, err := c.GetSecret(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		_, changed = mutate(written.RoleIDs(access))
+
+		return !changed, nil
+	})
+}
+
+// getSecretForUpdate fetches a secret's metadata along with its raw value,
+// which must only be written back as is, and its ETag, which is empty if
+// PrivX didn't send one.
+func (c *PrivXClient) getSecretForUpdate(ctx context.Context, name string) (*Secret, json.RawMessage, string, error) {
+	var raw struct {
+		Secret
+		Data json.RawMessage `json:"data"`
+	}
+	header, err := c.connector(ctx).
+		URL("/vault/api/v1/secrets/%s", url.PathEscape(name)).
+		Get(&raw)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return &raw.Secret, raw.Data, header.Get("ETag"), nil
+}
+
+func roleRefs(roleIds []string) []rolestore.RoleRef {
+	refs := make([]rolestore.RoleRef, 0, len(roleIds))
+	for _, id := range roleIds {
+		refs = append(refs, rolestore.RoleRef{ID: id})
+	}
+	return refs
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/conductorone/baton-privx/pkg/connector/client"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Config struct {
@@ -17,18 +20,37 @@ type Config struct {
 	ApiClientSecret   string
 	OAuthClientID     string
 	OAuthClientSecret string
+	RequestTimeout    time.Duration
+	SyncSessions      bool
+	TerminateSessions bool
 }
 
 type Connector struct {
 	client client.PrivXClient
+	// syncSessions adds the live PrivX sessions to the synced resources.
+	syncSessions bool
+	// terminateSessions ends a user's sessions when a role is revoked from
+	// them, so that the revoked access can't keep being used until the
+	// session expires. Deprovisioning a user always ends their sessions.
+	terminateSessions bool
 }
 
 // ResourceSyncers returns a ResourceSyncer for each resource type that should be synced from the upstream service.
 func (d *Connector) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
-	return []connectorbuilder.ResourceSyncer{
+	resourceSyncers := []connectorbuilder.ResourceSyncer{
 		newUserBuilder(d.client),
-		newRoleBuilder(d.client),
+		newRoleBuilder(d.client, d.terminateSessions),
+		newSourceBuilder(d.client),
+		newHostBuilder(d.client),
+		newPermissionBuilder(d.client),
+		newGroupBuilder(d.client),
+		newSecretBuilder(d.client),
+		newAccessGroupBuilder(d.client),
 	}
+	if d.syncSessions {
+		resourceSyncers = append(resourceSyncers, newSessionBuilder(d.client))
+	}
+	return resourceSyncers
 }
 
 // Asset takes an input AssetRef and attempts to fetch it using the connector's authenticated http client
@@ -50,7 +72,12 @@ func (d *Connector) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error)
 func (d *Connector) Validate(ctx context.Context) (annotations.Annotations, error) {
 	err := d.client.Verify(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("privx-connector: failed to validate client credentials: %w", err)
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument:
+			return nil, fmt.Errorf("privx-connector: failed to validate client credentials: %w", err)
+		default:
+			return nil, fmt.Errorf("privx-connector: failed to reach PrivX to validate client credentials: %w", err)
+		}
 	}
 
 	return nil, nil
@@ -64,6 +91,9 @@ func New(
 	apiClientSecret,
 	oAuthClientID,
 	oAuthClientSecret string,
+	requestTimeout time.Duration,
+	syncSessions bool,
+	terminateSessions bool,
 ) (*Connector, error) {
 	privXClient, err := client.NewPrivXClient(
 		ctx,
@@ -72,11 +102,16 @@ func New(
 		apiClientSecret,
 		oAuthClientID,
 		oAuthClientSecret,
+		client.WithRequestTimeout(requestTimeout),
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Connector{client: *privXClient}, nil
+	return &Connector{
+		client:            *privXClient,
+		syncSessions:      syncSessions,
+		terminateSessions: terminateSessions,
+	}, nil
 }
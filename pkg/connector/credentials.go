@@ -0,0 +1,119 @@
+package connector
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/crypto"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rotate replaces the credential of a user with a random one generated from
+// the credential options. API clients get a new client secret and users of
+// the PrivX local user store a new password. Directory users' passwords are
+// managed by their directory and can't be rotated, nor can the secret of the
+// API client the connector authenticates as.
+func (o *userBuilder) Rotate(
+	ctx context.Context,
+	resourceId *v2.ResourceId,
+	credentialOptions *v2.CredentialOptions,
+) ([]*v2.PlaintextData, annotations.Annotations, error) {
+	logger := ctxzap.Extract(ctx)
+	userId := resourceId.Resource
+
+	if o.client.IsConnectorAPIClient(userId) {
+		return nil, nil, status.Errorf(
+			codes.FailedPrecondition,
+			"baton-privx: API client %s is the one the connector authenticates as, its secret can't be rotated",
+			userId,
+		)
+	}
+
+	randomPassword := credentialOptions.GetRandomPassword()
+	if randomPassword == nil {
+		return nil, nil, status.Error(codes.InvalidArgument, "baton-privx: only random password credentials can be rotated")
+	}
+
+	credential, err := randomCredential(randomPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isAPIClient, err := userIsAPIClient(ctx, o.client, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isAPIClient {
+		err = o.client.SetAPIClientSecret(ctx, userId, credential)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		logger.Info("baton-privx: rotated API client secret", zap.String("user_id", userId))
+		return []*v2.PlaintextData{
+			{
+				Name:        "client_secret",
+				Description: "Secret of the PrivX API client",
+				Bytes:       []byte(credential),
+			},
+		}, nil, nil
+	}
+
+	_, err = o.client.GetLocalUser(ctx, userId)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil, status.Errorf(
+			codes.FailedPrecondition,
+			"baton-privx: user %s is not a local user nor an API client, its password is managed by its directory",
+			userId,
+		)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = o.client.SetLocalUserPassword(ctx, userId, credential)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info("baton-privx: rotated local user password", zap.String("user_id", userId))
+	return []*v2.PlaintextData{
+		{
+			Name:        "password",
+			Description: "Password of the PrivX local user",
+			Bytes:       []byte(credential),
+		},
+	}, nil, nil
+}
+
+// maxCredentialAttempts bounds how many random credentials are generated
+// looking for one that mixes every character class.
+const maxCredentialAttempts = 20
+
+// randomCredential generates a random password that has lower and upper case
+// letters, digits and symbols, as required by PrivX's password policy.
+func randomCredential(randomPassword *v2.CredentialOptions_RandomPassword) (string, error) {
+	for attempt := 0; attempt < maxCredentialAttempts; attempt++ {
+		credential, err := crypto.GenerateRandomPassword(randomPassword)
+		if err != nil {
+			return "", status.Error(codes.InvalidArgument, err.Error())
+		}
+		if hasEveryCharacterClass(credential) {
+			return credential, nil
+		}
+	}
+	return "", status.Error(codes.Internal, "baton-privx: failed generating a password that meets the password policy")
+}
+
+func hasEveryCharacterClass(credential string) bool {
+	return strings.IndexFunc(credential, unicode.IsLower) >= 0 &&
+		strings.IndexFunc(credential, unicode.IsUpper) >= 0 &&
+		strings.IndexFunc(credential, unicode.IsDigit) >= 0 &&
+		strings.IndexFunc(credential, func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) }) >= 0
+}
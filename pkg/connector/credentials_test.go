@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	apiClientId := "7f3a8c2e-1d4b-4e6f-a9c0-5b2d8e1f4a73"
+	localUserId := "0c7e3f2a-5b1d-4c8e-9f6a-2d4b8e0c1a35"
+	apiClientSecret := "old-secret"
+	var apiClientUpdate map[string]interface{}
+	apiClientReadsAfterUpdate := 0
+	localUserPassword := ""
+	apiClientsForbidden := false
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				if apiClientsForbidden && strings.HasPrefix(request.URL.Path, "/local-user-store/api/v1/api-clients/") {
+					writer.WriteHeader(http.StatusForbidden)
+					_, _ = writer.Write([]byte(`{"error_code": "FORBIDDEN"}`))
+					return
+				}
+				switch request.URL.Path {
+				case "/local-user-store/api/v1/api-clients/" + apiClientId:
+					if request.Method == http.MethodPut {
+						require.Nil(t, json.NewDecoder(request.Body).Decode(&apiClientUpdate))
+						apiClientSecret, _ = apiClientUpdate["secret"].(string)
+						return
+					}
+					if apiClientUpdate != nil {
+						apiClientReadsAfterUpdate++
+					}
+					_ = json.NewEncoder(writer).Encode(userstore.APIClient{
+						ID:               apiClientId,
+						Name:             "deploy-bot",
+						Secret:           apiClientSecret,
+						AuthClientID:     "deploy-bot-oauth",
+						AuthClientSecret: "oauth-secret",
+					})
+				case "/local-user-store/api/v1/users/" + localUserId + "/password":
+					password := userstore.Password{}
+					require.Nil(t, json.NewDecoder(request.Body).Decode(&password))
+					localUserPassword = password.Password
+				case "/local-user-store/api/v1/users/" + localUserId:
+					_, _ = writer.Write([]byte(`{"id": "` + localUserId + `", "username": "contractor"}`))
+				case "/auth/api/v1/oauth/token":
+					_, _ = writer.Write([]byte(`{}`))
+				default:
+					writer.WriteHeader(http.StatusNotFound)
+					_, _ = writer.Write([]byte(`{"error_code": "NOT_FOUND"}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+	credentialOptions := &v2.CredentialOptions{
+		Options: &v2.CredentialOptions_RandomPassword_{
+			RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16},
+		},
+	}
+
+	t.Run("should regenerate the secret of an API client", func(t *testing.T) {
+		plaintexts, _, err := userBuilder.Rotate(
+			ctx,
+			&v2.ResourceId{ResourceType: userResourceType.Id, Resource: apiClientId},
+			credentialOptions,
+		)
+		require.Nil(t, err)
+		require.Len(t, plaintexts, 1)
+		require.Equal(t, "client_secret", plaintexts[0].Name)
+		require.Equal(t, apiClientSecret, string(plaintexts[0].Bytes))
+
+		// Only the new secret is sent, and no secret is read back.
+		require.Equal(t, "deploy-bot", apiClientUpdate["name"])
+		require.NotContains(t, apiClientUpdate, "oauth_client_id")
+		require.NotContains(t, apiClientUpdate, "oauth_client_secret")
+		require.Equal(t, 0, apiClientReadsAfterUpdate)
+	})
+
+	t.Run("should set a new password for a local user", func(t *testing.T) {
+		plaintexts, _, err := userBuilder.Rotate(
+			ctx,
+			&v2.ResourceId{ResourceType: userResourceType.Id, Resource: localUserId},
+			credentialOptions,
+		)
+		require.Nil(t, err)
+		require.Len(t, plaintexts, 1)
+		require.Equal(t, "password", plaintexts[0].Name)
+		require.Equal(t, localUserPassword, string(plaintexts[0].Bytes))
+		require.True(t, hasEveryCharacterClass(localUserPassword))
+	})
+
+	t.Run("should rotate local users when API clients can't be read", func(t *testing.T) {
+		apiClientsForbidden = true
+		defer func() { apiClientsForbidden = false }()
+
+		plaintexts, _, err := userBuilder.Rotate(
+			ctx,
+			&v2.ResourceId{ResourceType: userResourceType.Id, Resource: localUserId},
+			credentialOptions,
+		)
+		require.Nil(t, err)
+		require.Len(t, plaintexts, 1)
+		require.Equal(t, "password", plaintexts[0].Name)
+		require.Equal(t, localUserPassword, string(plaintexts[0].Bytes))
+	})
+
+	t.Run("should refuse the API client the connector authenticates as", func(t *testing.T) {
+		apiClientUpdate = nil
+
+		_, _, err := userBuilder.Rotate(
+			ctx,
+			&v2.ResourceId{ResourceType: userResourceType.Id, Resource: "apiClientId"},
+			credentialOptions,
+		)
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.Nil(t, apiClientUpdate)
+	})
+
+	t.Run("should refuse directory users", func(t *testing.T) {
+		_, _, err := userBuilder.Rotate(
+			ctx,
+			&v2.ResourceId{ResourceType: userResourceType.Id, Resource: "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"},
+			credentialOptions,
+		)
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
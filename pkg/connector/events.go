@@ -0,0 +1,225 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/monitor"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Names of the PrivX audit events that are mapped to baton events, and the
+// keys of their messages that identify the resources involved.
+const (
+	auditEventRoleGranted       = "ROLE_GRANTED"
+	auditEventRoleRevoked       = "ROLE_REVOKED"
+	auditEventConnectionStarted = "CONNECTION_STARTED"
+	auditEventRoleUpdated       = "ROLE_UPDATED"
+
+	auditMessageUserIdKey = "user_id"
+	auditMessageRoleIdKey = "role_id"
+	auditMessageHostIdKey = "host_id"
+)
+
+// eventCursor is the position of the event feed: the creation time of the
+// last event returned, and the IDs of the events returned that were created
+// at that time. The feed restarts from there on each call, so events pruned
+// by the monitor-service's retention don't shift it.
+type eventCursor struct {
+	StartTime string   `json:"start_time"`
+	SeenIds   []string `json:"seen_ids,omitempty"`
+}
+
+// ListEvents pages through the PrivX audit events recorded since
+// earliestEvent and returns the ones that map to baton events: role grants
+// and revokes, and connection starts as usage of the host. Role edits have no
+// baton event yet and are left to the next full sync.
+func (d *Connector) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	logger := ctxzap.Extract(ctx)
+
+	cursor, err := parseEventCursor(earliestEvent, pToken)
+	if err != nil {
+		return nil, nil, nil, status.Errorf(codes.InvalidArgument, "baton-privx: invalid event cursor: %s", err)
+	}
+
+	limit := ResourcePageSizeDefault
+	if pToken != nil && pToken.Size > 0 {
+		limit = pToken.Size
+	}
+
+	// The search starts at the cursor's time inclusive, the events already
+	// seen at that time are fetched again and skipped.
+	auditEvents, nextToken, rateLimit, err := d.client.GetAuditEvents(ctx, cursor.StartTime, 0, limit+len(cursor.SeenIds))
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, nil, outputAnnotations, err
+	}
+
+	events := make([]*v2.Event, 0)
+	for _, auditEvent := range auditEvents {
+		auditEventCopy := auditEvent
+		id := auditEventId(&auditEventCopy)
+		if auditEvent.Created == cursor.StartTime && slices.Contains(cursor.SeenIds, id) {
+			continue
+		}
+		cursor.advance(auditEvent.Created, id)
+
+		event, err := auditEventToEvent(&auditEventCopy)
+		if err != nil {
+			logger.Debug(
+				"baton-privx: skipping audit event",
+				zap.String("event_name", auditEvent.EventName),
+				zap.Error(err),
+			)
+			continue
+		}
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+
+	nextCursor, err := json.Marshal(cursor)
+	if err != nil {
+		return nil, nil, outputAnnotations, err
+	}
+
+	return events, &pagination.StreamState{
+		Cursor:  string(nextCursor),
+		HasMore: nextToken != "",
+	}, outputAnnotations, nil
+}
+
+// parseEventCursor reads the cursor of the event feed, or opens a new one at
+// earliestEvent.
+func parseEventCursor(earliestEvent *timestamppb.Timestamp, pToken *pagination.StreamToken) (*eventCursor, error) {
+	cursor := &eventCursor{}
+	if pToken != nil && pToken.Cursor != "" {
+		err := json.Unmarshal([]byte(pToken.Cursor), cursor)
+		if err != nil {
+			return nil, err
+		}
+		return cursor, nil
+	}
+
+	if earliestEvent != nil {
+		cursor.StartTime = earliestEvent.AsTime().UTC().Format(time.RFC3339Nano)
+	}
+	return cursor, nil
+}
+
+// advance moves the cursor past an event.
+func (c *eventCursor) advance(created string, id string) {
+	if created != c.StartTime {
+		c.StartTime = created
+		c.SeenIds = nil
+	}
+	c.SeenIds = append(c.SeenIds, id)
+}
+
+// auditEventToEvent converts a PrivX audit event into a baton event, or
+// returns nil for events that have no baton counterpart.
+func auditEventToEvent(auditEvent *monitor.AuditEvent) (*v2.Event, error) {
+	occurredAt, err := time.Parse(time.RFC3339Nano, auditEvent.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &v2.Event{
+		Id:         auditEventId(auditEvent),
+		OccurredAt: timestamppb.New(occurredAt),
+	}
+
+	message := auditEvent.Message
+	switch auditEvent.EventName {
+	case auditEventRoleGranted, auditEventRoleRevoked:
+		userId, roleId := message[auditMessageUserIdKey], message[auditMessageRoleIdKey]
+		if userId == "" || roleId == "" {
+			return nil, status.Error(codes.InvalidArgument, "missing user or role ID")
+		}
+
+		role := eventResource(roleResourceType, roleId)
+		user := eventResource(userResourceType, userId)
+		if auditEvent.EventName == auditEventRoleGranted {
+			event.Event = &v2.Event_GrantEvent{
+				GrantEvent: &v2.GrantEvent{
+					Grant: grant.NewGrant(role, EntitlementAssigned, user.Id),
+				},
+			}
+		} else {
+			event.Event = &v2.Event_RevokeEvent{
+				RevokeEvent: &v2.RevokeEvent{
+					Entitlement: entitlement.NewAssignmentEntitlement(role, EntitlementAssigned),
+					Principal:   user,
+				},
+			}
+		}
+	case auditEventConnectionStarted:
+		userId, hostId := message[auditMessageUserIdKey], message[auditMessageHostIdKey]
+		if userId == "" || hostId == "" {
+			return nil, status.Error(codes.InvalidArgument, "missing user or host ID")
+		}
+
+		event.Event = &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				TargetResource: eventResource(hostResourceType, hostId),
+				ActorResource:  eventResource(userResourceType, userId),
+			},
+		}
+	case auditEventRoleUpdated:
+		// Baton has no resource change event to report role edits with yet.
+		return nil, nil
+	default:
+		return nil, nil
+	}
+
+	return event, nil
+}
+
+// auditEventId derives a stable ID for an audit event from its contents.
+// PrivX's event_id is the code of the event's type, shared by every event of
+// that type, so it only goes into the hash.
+func auditEventId(auditEvent *monitor.AuditEvent) string {
+	keys := make([]string, 0, len(auditEvent.Message))
+	for key := range auditEvent.Message {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hasher := sha256.New()
+	for _, field := range []string{auditEvent.Created, auditEvent.ServiceID, auditEvent.EventID, auditEvent.EventName} {
+		hasher.Write([]byte(strconv.Quote(field)))
+	}
+	for _, key := range keys {
+		hasher.Write([]byte(strconv.Quote(key) + strconv.Quote(auditEvent.Message[key])))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// eventResource references a resource by its ID in an event.
+func eventResource(resourceType *v2.ResourceType, id string) *v2.Resource {
+	return &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: resourceType.Id,
+			Resource:     id,
+		},
+	}
+}
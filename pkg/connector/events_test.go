@@ -0,0 +1,142 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/monitor"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestListEvents(t *testing.T) {
+	ctx := context.Background()
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	auditEvents := []monitor.AuditEvent{
+		{
+			EventID:   "1600",
+			EventName: auditEventRoleGranted,
+			Created:   "2024-07-09T10:00:00Z",
+			Message:   map[string]string{"user_id": userId, "role_id": "3453395a-2a12-50a5-4fdb-794d567edae0"},
+		},
+		{
+			EventID:   "1600",
+			EventName: auditEventRoleGranted,
+			Created:   "2024-07-09T10:05:00Z",
+			Message:   map[string]string{"user_id": userId, "role_id": "9b1f0c3e-6d2a-4e8b-a7c5-1f3d5e7a9b2c"},
+		},
+		{
+			EventID:   "1601",
+			EventName: auditEventRoleRevoked,
+			Created:   "2024-07-09T10:05:00Z",
+			Message:   map[string]string{"user_id": userId, "role_id": "3453395a-2a12-50a5-4fdb-794d567edae0"},
+		},
+		{
+			EventName: auditEventConnectionStarted,
+			Created:   "2024-07-09T10:10:00Z",
+			Message:   map[string]string{"user_id": userId, "host_id": "5b0e6a21-7c44-4a3e-8d2f-0e9b1c3a7d58"},
+		},
+		{
+			EventName: auditEventRoleUpdated,
+			Created:   "2024-07-09T10:15:00Z",
+			Message:   map[string]string{"role_id": "3453395a-2a12-50a5-4fdb-794d567edae0"},
+		},
+	}
+	var searches []monitor.AuditEventSearchObject
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				if request.URL.Path != "/monitor-service/api/v1/auditevents/search" {
+					_, _ = writer.Write([]byte(`{}`))
+					return
+				}
+
+				search := monitor.AuditEventSearchObject{}
+				require.Nil(t, json.NewDecoder(request.Body).Decode(&search))
+				searches = append(searches, search)
+				require.Empty(t, request.URL.Query().Get("offset"))
+				limit, err := strconv.Atoi(request.URL.Query().Get("limit"))
+				require.Nil(t, err)
+
+				// Events created at the start time or later, oldest first.
+				items := make([]monitor.AuditEvent, 0)
+				for _, auditEvent := range auditEvents {
+					if auditEvent.Created >= search.StartTime && len(items) < limit {
+						items = append(items, auditEvent)
+					}
+				}
+				_ = json.NewEncoder(writer).Encode(monitor.EventsResult{Count: len(items), Items: items})
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	connector := &Connector{client: *privXClient}
+
+	earliestEvent := timestamppb.New(time.Date(2024, 7, 9, 0, 0, 0, 0, time.UTC))
+	events, streamState, _, err := connector.ListEvents(ctx, earliestEvent, &pagination.StreamToken{Size: 2})
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "2024-07-09T00:00:00Z", searches[0].StartTime)
+	require.NotNil(t, events[0].GetGrantEvent())
+	require.Equal(t, userId, events[0].GetGrantEvent().Grant.Principal.Id.Resource)
+	require.NotNil(t, events[1].GetGrantEvent())
+	// PrivX's event_id is the code of the event type, events of the same type
+	// are still told apart.
+	require.NotEqual(t, events[0].Id, events[1].Id)
+	require.NotEqual(t, "1600", events[0].Id)
+	require.Equal(t, events[0].Id, auditEventId(&auditEvents[0]))
+	require.True(t, streamState.HasMore)
+
+	// The oldest event is pruned by the monitor-service's retention. The
+	// cursor carries on from the last event's time, whatever earliestEvent
+	// is, and skips the event it already returned at that time.
+	auditEvents = auditEvents[1:]
+	events, streamState, _, err = connector.ListEvents(ctx, nil, &pagination.StreamToken{Size: 2, Cursor: streamState.Cursor})
+	require.Nil(t, err)
+	require.Equal(t, "2024-07-09T10:05:00Z", searches[1].StartTime)
+	require.Len(t, events, 2)
+	require.NotNil(t, events[0].GetRevokeEvent())
+	require.Equal(t, EntitlementAssigned, events[0].GetRevokeEvent().Entitlement.Slug)
+	require.NotNil(t, events[1].GetUsageEvent())
+	require.Equal(t, hostResourceType.Id, events[1].GetUsageEvent().TargetResource.Id.ResourceType)
+	require.True(t, streamState.HasMore)
+
+	// Role edits have no baton event, but still move the cursor.
+	events, streamState, _, err = connector.ListEvents(ctx, nil, &pagination.StreamToken{Size: 2, Cursor: streamState.Cursor})
+	require.Nil(t, err)
+	require.Len(t, events, 0)
+	require.False(t, streamState.HasMore)
+
+	// An event recorded later at the same time as the last one is returned.
+	auditEvents = append(auditEvents, monitor.AuditEvent{
+		EventID:   "1600",
+		EventName: auditEventRoleGranted,
+		Created:   "2024-07-09T10:15:00Z",
+		Message:   map[string]string{"user_id": userId, "role_id": "3453395a-2a12-50a5-4fdb-794d567edae0"},
+	})
+	events, streamState, _, err = connector.ListEvents(ctx, nil, &pagination.StreamToken{Size: 2, Cursor: streamState.Cursor})
+	require.Nil(t, err)
+	require.Equal(t, "2024-07-09T10:15:00Z", searches[3].StartTime)
+	require.Len(t, events, 1)
+	require.NotNil(t, events[0].GetGrantEvent())
+	require.False(t, streamState.HasMore)
+}
@@ -0,0 +1,238 @@
+package connector
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const EntitlementMember = "member"
+
+type groupBuilder struct {
+	client client.PrivXClient
+}
+
+// sourceGroup is a directory group referenced by role source rules: the users
+// of a source matching a search string.
+type sourceGroup struct {
+	sourceId     string
+	searchString string
+	roleIds      []string
+}
+
+func (o *groupBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return groupResourceType
+}
+
+// List returns one resource per distinct source and search string referenced
+// by the source rules of any role. PrivX doesn't list directory groups, so
+// every role is read to collect them.
+func (o *groupBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	privXRoles, rateLimit, err := o.client.GetAllRoles(ctx)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching roles", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	groupResources := make([]*v2.Resource, 0)
+	for _, group := range sourceGroups(privXRoles) {
+		groupCopy := group
+		newResource, err := groupResource(ctx, &groupCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		groupResources = append(groupResources, newResource)
+	}
+
+	return groupResources, "", outputAnnotations, nil
+}
+
+func (o *groupBuilder) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlements := []*v2.Entitlement{
+		entitlement.NewAssignmentEntitlement(
+			resource,
+			EntitlementMember,
+			entitlement.WithGrantableTo(userResourceType),
+			entitlement.WithDescription(fmt.Sprintf("Member of the %s directory group", resource.DisplayName)),
+			entitlement.WithDisplayName(fmt.Sprintf("%s group %s", resource.DisplayName, EntitlementMember)),
+		),
+	}
+	return entitlements, "", nil, nil
+}
+
+// Grants returns the users the group's source rule clause matches, as
+// evaluated by PrivX. Membership is managed in the directory.
+func (o *groupBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	pToken *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	sourceId, searchString, err := parseGroupResourceId(resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	privXUsers, rateLimit, err := o.client.GetSourceRuleMembers(ctx, sourceId, searchString)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", outputAnnotations, err
+	}
+
+	var memberGrants []*v2.Grant
+	for _, user := range privXUsers {
+		memberGrants = append(
+			memberGrants,
+			grant.NewGrant(
+				resource,
+				EntitlementMember,
+				&v2.ResourceId{
+					ResourceType: userResourceType.Id,
+					Resource:     user.ID,
+				},
+				grant.WithAnnotation(&v2.GrantImmutable{}),
+			),
+		)
+	}
+
+	return memberGrants, "", outputAnnotations, nil
+}
+
+func newGroupBuilder(client client.PrivXClient) *groupBuilder {
+	return &groupBuilder{client: client}
+}
+
+// sourceGroups walks the source rules of every role and returns the distinct
+// directory groups they reference, along with the roles referencing them.
+// Clauses without a search string map the whole source and are granted to the
+// source itself instead.
+func sourceGroups(roles []rolestore.Role) []sourceGroup {
+	groupsById := make(map[string]*sourceGroup)
+	for _, role := range roles {
+		for _, leaf := range client.SourceRuleLeaves(role.SourceRule) {
+			if leaf.Source == "" || leaf.Pattern == "" {
+				continue
+			}
+
+			id := groupResourceId(leaf.Source, leaf.Pattern)
+			group, ok := groupsById[id]
+			if !ok {
+				group = &sourceGroup{sourceId: leaf.Source, searchString: leaf.Pattern}
+				groupsById[id] = group
+			}
+			if len(group.roleIds) == 0 || group.roleIds[len(group.roleIds)-1] != role.ID {
+				group.roleIds = append(group.roleIds, role.ID)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(groupsById))
+	for id := range groupsById {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	groups := make([]sourceGroup, 0, len(ids))
+	for _, id := range ids {
+		groups = append(groups, *groupsById[id])
+	}
+	return groups
+}
+
+// groupResourceId derives the ID of a directory group resource from its
+// source and search string. The search string is encoded so that the ID
+// doesn't contain the separators used in entitlement and grant IDs.
+func groupResourceId(sourceId, searchString string) string {
+	return sourceId + "/" + base64.RawURLEncoding.EncodeToString([]byte(searchString))
+}
+
+// parseGroupResourceId returns the source and search string of a directory
+// group resource ID.
+func parseGroupResourceId(id string) (string, string, error) {
+	sourceId, encoded, ok := strings.Cut(id, "/")
+	if !ok {
+		return "", "", status.Errorf(codes.InvalidArgument, "baton-privx: invalid group id %s", id)
+	}
+
+	searchString, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", status.Errorf(codes.InvalidArgument, "baton-privx: invalid group id %s: %v", id, err)
+	}
+
+	return sourceId, string(searchString), nil
+}
+
+// groupMemberEntitlementID returns the ID of the `member` entitlement of the
+// given directory group, used to expand role grants to its members.
+func groupMemberEntitlementID(groupId string) string {
+	return entitlement.NewEntitlementID(
+		&v2.Resource{
+			Id: &v2.ResourceId{
+				ResourceType: groupResourceType.Id,
+				Resource:     groupId,
+			},
+		},
+		EntitlementMember,
+	)
+}
+
+// groupResource Converts a directory group referenced by role source rules
+// into a ConductorOne Resource. Groups are parented by their source.
+func groupResource(ctx context.Context, group *sourceGroup) (*v2.Resource, error) {
+	createdResource, err := resource.NewGroupResource(
+		group.searchString,
+		groupResourceType,
+		groupResourceId(group.sourceId, group.searchString),
+		[]resource.GroupTraitOption{
+			resource.WithGroupProfile(
+				map[string]interface{}{
+					"source":        group.sourceId,
+					"search_string": group.searchString,
+					"role_ids":      strings.Join(group.roleIds, ","),
+				},
+			),
+		},
+		resource.WithParentResourceID(
+			&v2.ResourceId{
+				ResourceType: sourceResourceType.Id,
+				Resource:     group.sourceId,
+			},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
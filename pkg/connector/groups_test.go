@@ -0,0 +1,57 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupsList(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/roles_page_0.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	groupBuilder := newGroupBuilder(*privXClient)
+
+	resources, nextToken, _, err := groupBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, "", nextToken)
+	require.Len(t, resources, 1)
+	require.Equal(t, "(principal=c1privxadmin)", resources[0].DisplayName)
+	require.Equal(t, sourceResourceType.Id, resources[0].ParentResourceId.ResourceType)
+	require.Equal(t, "a1ee631f-9698-4a77-8042-2002602f9d08", resources[0].ParentResourceId.Resource)
+
+	sourceId, searchString, err := parseGroupResourceId(resources[0].Id.Resource)
+	require.Nil(t, err)
+	require.Equal(t, "a1ee631f-9698-4a77-8042-2002602f9d08", sourceId)
+	require.Equal(t, "(principal=c1privxadmin)", searchString)
+}
@@ -0,0 +1,203 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	EntitlementPrincipal = "principal"
+)
+
+type hostBuilder struct {
+	client client.PrivXClient
+	// hosts are kept by ID as they are listed, reset on the first page of
+	// each sync, so that their principals don't need fetching again for
+	// entitlements and grants.
+	hosts map[string]*client.Host
+}
+
+func (o *hostBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return hostResourceType
+}
+
+// List returns all the hosts from the host-store as resource objects.
+func (o *hostBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	offset, limit, err := parsePageToken(pToken)
+	if err != nil {
+		logger.Error("invalid page token", zap.Error(err))
+	}
+
+	privXHosts, nextToken, rateLimit, err := o.client.GetHosts(ctx, offset, limit)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching hosts", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	if pToken.Token == "" || o.hosts == nil {
+		o.hosts = make(map[string]*client.Host)
+	}
+
+	hostResources := make([]*v2.Resource, 0)
+	for _, host := range privXHosts {
+		hostCopy := host
+		o.hosts[host.ID] = &hostCopy
+		newResource, err := hostResource(ctx, &hostCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		hostResources = append(hostResources, newResource)
+	}
+
+	return hostResources, nextToken, outputAnnotations, nil
+}
+
+// Entitlements returns one entitlement per host principal (target account),
+// e.g. `host:<id>:principal:root`.
+func (o *hostBuilder) Entitlements(
+	ctx context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	host, outputAnnotations, err := o.getHost(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", outputAnnotations, err
+	}
+
+	entitlements := make([]*v2.Entitlement, 0, len(host.Principals))
+	for _, principal := range host.Principals {
+		entitlements = append(
+			entitlements,
+			entitlement.NewAssignmentEntitlement(
+				resource,
+				principalEntitlementName(principal.Principal),
+				entitlement.WithGrantableTo(roleResourceType),
+				entitlement.WithDescription(
+					fmt.Sprintf("Can log in as %s on %s", principal.Principal, resource.DisplayName),
+				),
+				entitlement.WithDisplayName(
+					fmt.Sprintf("%s principal %s", resource.DisplayName, principal.Principal),
+				),
+			),
+		)
+	}
+
+	return entitlements, "", outputAnnotations, nil
+}
+
+// Grants returns a grant on each host principal for every role mapped to it.
+// The grants are expandable to the members of the role.
+func (o *hostBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	pToken *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	host, outputAnnotations, err := o.getHost(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", outputAnnotations, err
+	}
+
+	var principalGrants []*v2.Grant
+	for _, principal := range host.Principals {
+		for _, role := range principal.Roles {
+			principalGrants = append(
+				principalGrants,
+				grant.NewGrant(
+					resource,
+					principalEntitlementName(principal.Principal),
+					&v2.ResourceId{
+						ResourceType: roleResourceType.Id,
+						Resource:     role.ID,
+					},
+					grant.WithAnnotation(
+						&v2.GrantExpandable{
+							EntitlementIds: []string{roleAssignedEntitlementID(role.ID)},
+						},
+					),
+				),
+			)
+		}
+	}
+
+	return principalGrants, "", outputAnnotations, nil
+}
+
+// getHost returns a host as it was listed, or fetches it if it wasn't.
+func (o *hostBuilder) getHost(ctx context.Context, hostId string) (*client.Host, annotations.Annotations, error) {
+	if host, ok := o.hosts[hostId]; ok {
+		return host, nil, nil
+	}
+
+	host, rateLimit, err := o.client.GetHost(ctx, hostId)
+	return host, rateLimitAnnotations(rateLimit), err
+}
+
+// principalEntitlementName returns the entitlement slug for a host principal.
+func principalEntitlementName(principal string) string {
+	return fmt.Sprintf("%s:%s", EntitlementPrincipal, principal)
+}
+
+func newHostBuilder(client client.PrivXClient) *hostBuilder {
+	return &hostBuilder{client: client}
+}
+
+// hostResource Converts a PrivX Host into a ConductorOne Resource.
+func hostResource(ctx context.Context, host *client.Host) (*v2.Resource, error) {
+	statuses := make([]string, 0, len(host.Status))
+	for _, status := range host.Status {
+		statuses = append(statuses, fmt.Sprintf("%s=%s", status.K, status.V))
+	}
+
+	createdResource, err := resource.NewAppResource(
+		host.Name,
+		hostResourceType,
+		host.ID,
+		[]resource.AppTraitOption{
+			resource.WithAppProfile(
+				map[string]interface{}{
+					"name":                  host.Name,
+					"addresses":             strings.Join(host.Addresses, ","),
+					"cloud_provider":        host.CloudProvider,
+					"cloud_provider_region": host.CloudProviderRegion,
+					"instance_id":           host.InstanceID,
+					"external_id":           host.ExternalID,
+					"tags":                  strings.Join(host.Tags, ","),
+					"audit_enabled":         host.Audit,
+					"deployable":            host.Deployable,
+					"disabled":              host.Disabled,
+					"deployment_status":     strings.Join(statuses, ","),
+				},
+			),
+		},
+		resource.WithDescription(host.Comment),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
@@ -0,0 +1,159 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostsList(t *testing.T) {
+	ctx := context.Background()
+	t.Run("should receive hosts", func(t *testing.T) {
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					writer.Header().Set(uhttp.ContentType, "application/json")
+					writer.WriteHeader(http.StatusOK)
+					json, err := os.ReadFile("./client/fixtures/hosts_page_0.json")
+					require.Nil(t, err)
+					_, err = writer.Write(json)
+					if err != nil {
+						return
+					}
+				},
+			),
+		)
+		defer server.Close()
+
+		privXClient, err := client.NewPrivXClient(
+			ctx,
+			server.URL,
+			"apiClientId",
+			"apiClientSecret",
+			"oauthClientId",
+			"oauthClientSecret",
+		)
+		require.Nil(t, err)
+		hostBuilder := newHostBuilder(*privXClient)
+
+		resources, token, annotations, err := hostBuilder.List(ctx, nil, &pagination.Token{})
+		require.Nil(t, err)
+
+		require.Len(t, resources, 2)
+		require.Equal(t, "6c2d4e1a-0f9b-4c43-6e0b-1a3f9e2d7c11", resources[0].Id.Resource)
+		require.Equal(t, "prod-db-01", resources[0].DisplayName)
+
+		require.Equal(t, "", token)
+		require.Len(t, annotations, 0)
+	})
+}
+
+func TestHostsPrincipals(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/host_0.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	hostBuilder := newHostBuilder(*privXClient)
+
+	host, err := hostResource(ctx, &client.Host{ID: "6c2d4e1a-0f9b-4c43-6e0b-1a3f9e2d7c11", Name: "prod-db-01"})
+	require.Nil(t, err)
+
+	t.Run("should list principals as entitlements", func(t *testing.T) {
+		entitlements, _, _, err := hostBuilder.Entitlements(ctx, host, &pagination.Token{})
+		require.Nil(t, err)
+		require.Len(t, entitlements, 2)
+		require.Equal(t, "host:6c2d4e1a-0f9b-4c43-6e0b-1a3f9e2d7c11:principal:root", entitlements[0].Id)
+	})
+
+	t.Run("should grant principals to mapped roles", func(t *testing.T) {
+		grants, _, _, err := hostBuilder.Grants(ctx, host, &pagination.Token{})
+		require.Nil(t, err)
+		require.Len(t, grants, 1)
+		require.Equal(t, "host:6c2d4e1a-0f9b-4c43-6e0b-1a3f9e2d7c11:principal:root", grants[0].Entitlement.Id)
+		require.Equal(t, roleResourceType.Id, grants[0].Principal.Id.ResourceType)
+		require.Equal(t, "3453395a-2a12-50a5-4fdb-794d567edae0", grants[0].Principal.Id.Resource)
+
+		expandable := &v2.GrantExpandable{}
+		grantAnnotations := annotations.Annotations(grants[0].Annotations)
+		ok, err := grantAnnotations.Pick(expandable)
+		require.Nil(t, err)
+		require.True(t, ok)
+		require.Equal(t, []string{"role:3453395a-2a12-50a5-4fdb-794d567edae0:assigned"}, expandable.EntitlementIds)
+	})
+}
+
+func TestHostsPrincipalsFromList(t *testing.T) {
+	ctx := context.Background()
+	hostRequests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				if strings.HasPrefix(request.URL.Path, "/host-store/api/v1/hosts/") {
+					hostRequests++
+				}
+				json, err := os.ReadFile("./client/fixtures/hosts_page_0.json")
+				require.Nil(t, err)
+				_, _ = writer.Write(json)
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	hostBuilder := newHostBuilder(*privXClient)
+
+	resources, _, _, err := hostBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+
+	entitlements, _, _, err := hostBuilder.Entitlements(ctx, resources[0], &pagination.Token{})
+	require.Nil(t, err)
+	require.NotEmpty(t, entitlements)
+
+	grants, _, _, err := hostBuilder.Grants(ctx, resources[0], &pagination.Token{})
+	require.Nil(t, err)
+	require.NotEmpty(t, grants)
+
+	// The principals come from the listed hosts, not one request per host.
+	require.Equal(t, 0, hostRequests)
+}
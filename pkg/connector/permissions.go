@@ -0,0 +1,161 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+type permissionBuilder struct {
+	client client.PrivXClient
+	// roleIdsByPermission are the IDs of the roles holding each permission,
+	// collected by List and reused for the grants of every permission.
+	roleIdsByPermission map[string][]string
+}
+
+func (o *permissionBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return permissionResourceType
+}
+
+// List returns one resource per distinct permission held by any role. PrivX
+// has no permissions endpoint, so every role is read to collect them.
+func (o *permissionBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	privXRoles, rateLimit, err := o.client.GetAllRoles(ctx)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching roles", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	o.roleIdsByPermission = groupRoleIdsByPermission(privXRoles)
+
+	permissions := make([]string, 0, len(o.roleIdsByPermission))
+	for permission := range o.roleIdsByPermission {
+		permissions = append(permissions, permission)
+	}
+	slices.Sort(permissions)
+
+	permissionResources := make([]*v2.Resource, 0, len(permissions))
+	for _, permission := range permissions {
+		newResource, err := permissionResource(ctx, permission)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		permissionResources = append(permissionResources, newResource)
+	}
+
+	return permissionResources, "", outputAnnotations, nil
+}
+
+func (o *permissionBuilder) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlements := []*v2.Entitlement{
+		entitlement.NewAssignmentEntitlement(
+			resource,
+			EntitlementAssigned,
+			entitlement.WithGrantableTo(roleResourceType),
+			entitlement.WithDescription(fmt.Sprintf("Has the %s permission", resource.DisplayName)),
+			entitlement.WithDisplayName(fmt.Sprintf("%s permission %s", resource.DisplayName, EntitlementAssigned)),
+		),
+	}
+	return entitlements, "", nil, nil
+}
+
+// Grants grants the permission to each role that holds it. The grants are
+// expandable to the members of the role.
+func (o *permissionBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	var outputAnnotations annotations.Annotations
+	if o.roleIdsByPermission == nil {
+		privXRoles, rateLimit, err := o.client.GetAllRoles(ctx)
+		outputAnnotations = rateLimitAnnotations(rateLimit)
+		if err != nil {
+			return nil, "", outputAnnotations, err
+		}
+		o.roleIdsByPermission = groupRoleIdsByPermission(privXRoles)
+	}
+
+	var permissionGrants []*v2.Grant
+	for _, roleId := range o.roleIdsByPermission[resource.Id.Resource] {
+		permissionGrants = append(
+			permissionGrants,
+			grant.NewGrant(
+				resource,
+				EntitlementAssigned,
+				&v2.ResourceId{
+					ResourceType: roleResourceType.Id,
+					Resource:     roleId,
+				},
+				grant.WithAnnotation(
+					&v2.GrantExpandable{
+						EntitlementIds: []string{roleAssignedEntitlementID(roleId)},
+					},
+				),
+			),
+		)
+	}
+
+	return permissionGrants, "", outputAnnotations, nil
+}
+
+// groupRoleIdsByPermission maps every permission held by any of the roles to
+// the IDs of the roles holding it.
+func groupRoleIdsByPermission(privXRoles []rolestore.Role) map[string][]string {
+	roleIdsByPermission := make(map[string][]string)
+	for _, role := range privXRoles {
+		for _, permission := range role.Permissions {
+			if !slices.Contains(roleIdsByPermission[permission], role.ID) {
+				roleIdsByPermission[permission] = append(roleIdsByPermission[permission], role.ID)
+			}
+		}
+	}
+	return roleIdsByPermission
+}
+
+func newPermissionBuilder(client client.PrivXClient) *permissionBuilder {
+	return &permissionBuilder{client: client}
+}
+
+// permissionResource Converts a PrivX permission name into a ConductorOne
+// Resource.
+func permissionResource(ctx context.Context, permission string) (*v2.Resource, error) {
+	createdResource, err := resource.NewResource(
+		permission,
+		permissionResourceType,
+		permission,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissions(t *testing.T) {
+	ctx := context.Background()
+	roleLists := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				if request.URL.Path == "/role-store/api/v1/roles" {
+					roleLists++
+				}
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/roles_page_0.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	permissionBuilder := newPermissionBuilder(*privXClient)
+
+	t.Run("should list distinct permissions", func(t *testing.T) {
+		resources, token, _, err := permissionBuilder.List(ctx, nil, &pagination.Token{})
+		require.Nil(t, err)
+		require.Len(t, resources, 41)
+		require.Equal(t, "", token)
+	})
+
+	t.Run("should grant permissions to roles", func(t *testing.T) {
+		permission, err := permissionResource(ctx, "users-manage")
+		require.Nil(t, err)
+
+		grants, _, _, err := permissionBuilder.Grants(ctx, permission, &pagination.Token{})
+		require.Nil(t, err)
+		require.Len(t, grants, 1)
+		require.Equal(t, "3453395a-2a12-50a5-4fdb-794d567edae0", grants[0].Principal.Id.Resource)
+
+		// The roles read by List are reused.
+		require.Equal(t, 1, roleLists)
+	})
+}
@@ -0,0 +1,18 @@
+package connector
+
+import (
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+)
+
+// rateLimitAnnotations returns the rate limit reported by PrivX as
+// annotations, so that the syncer can pace itself, or nil if there was none.
+func rateLimitAnnotations(rateLimit *v2.RateLimitDescription) annotations.Annotations {
+	if rateLimit == nil {
+		return nil
+	}
+
+	var outputAnnotations annotations.Annotations
+	outputAnnotations.WithRateLimiting(rateLimit)
+	return outputAnnotations
+}
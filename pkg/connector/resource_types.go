@@ -2,6 +2,7 @@ package connector
 
 import (
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 )
 
 // The user resource type is for all user objects from the database.
@@ -17,3 +18,60 @@ var roleResourceType = &v2.ResourceType{
 	DisplayName: "Role",
 	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_ROLE},
 }
+
+// The source resource type is for the user and host directories (LDAP, AD,
+// OIDC, local, etc.) that PrivX users are imported from.
+var sourceResourceType = &v2.ResourceType{
+	Id:          "source",
+	DisplayName: "Source",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
+	Annotations: annotations.New(&v2.SkipEntitlementsAndGrants{}),
+}
+
+// The group resource type is for the directory groups (a source and a search
+// string) that roles are mapped to through their source rules.
+var groupResourceType = &v2.ResourceType{
+	Id:          "group",
+	DisplayName: "Group",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
+}
+
+// The host resource type is for all target hosts from the host-store.
+var hostResourceType = &v2.ResourceType{
+	Id:          "host",
+	DisplayName: "Host",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_APP},
+}
+
+// The permission resource type is for the distinct PrivX permissions (e.g.
+// `users-manage`) held by roles.
+var permissionResourceType = &v2.ResourceType{
+	Id:          "permission",
+	DisplayName: "Permission",
+}
+
+// The secret resource type is for the secrets stored in the PrivX Vault. baton
+// has no secret trait, so the app trait is used to carry their profile.
+var secretResourceType = &v2.ResourceType{
+	Id:          "secret",
+	DisplayName: "Secret",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_APP},
+}
+
+// The access group resource type is for the PrivX access groups that scope
+// host and user management rights, and that every role belongs to.
+var accessGroupResourceType = &v2.ResourceType{
+	Id:          "access_group",
+	DisplayName: "Access Group",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
+}
+
+// The session resource type is for the live PrivX web and API sessions of
+// users, synced only when enabled. baton has no session trait, so the app
+// trait is used to carry their profile.
+var sessionResourceType = &v2.ResourceType{
+	Id:          "session",
+	DisplayName: "Session",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_APP},
+	Annotations: annotations.New(&v2.SkipEntitlementsAndGrants{}),
+}
@@ -2,7 +2,11 @@ package connector
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/conductorone/baton-privx/pkg/connector/client"
@@ -14,14 +18,38 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	EntitlementAssigned = "assigned"
+
+	// Keys of a GrantMetadata annotation on a grant request's entitlement that
+	// ask for a time-bound grant instead of a permanent one.
+	grantEndKey      = "grant_end"
+	grantDurationKey = "grant_duration"
+	// Key of a GrantMetadata annotation on a grant request's entitlement that
+	// narrows down the users of a source granted a role, e.g. to a directory
+	// group. All users of the source are granted the role without it.
+	searchStringKey = "search_string"
 )
 
 type roleBuilder struct {
 	client client.PrivXClient
+	// terminateSessions ends a user's sessions when a role is revoked from
+	// them.
+	terminateSessions bool
+	// apiClientsById are fetched by the first Grants call of each sync and
+	// reused for every role.
+	apiClientsById map[string]*client.APIClient
+	// userRoles are the role memberships of the users that are members of a
+	// role, which the member listing doesn't carry. Each user's are read once
+	// per sync and reused for every role they are a member of.
+	userRoles map[string][]rolestore.Role
+	// rolesById are the roles as they were listed, source rules included, so
+	// that they don't need fetching again for grants.
+	rolesById map[string]*rolestore.Role
 }
 
 func (o *roleBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -47,15 +75,27 @@ func (o *roleBuilder) List(
 		logger.Error("invalid page token", zap.Error(err))
 	}
 
-	privXRoles, nextToken, err := o.client.GetRoles(ctx, offset, limit)
+	privXRoles, nextToken, rateLimit, err := o.client.GetRoles(ctx, offset, limit)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
 	if err != nil {
 		logger.Debug("Error fetching users", zap.Error(err))
-		return nil, "", nil, err
+		return nil, "", outputAnnotations, err
+	}
+
+	// Roles are listed before their grants, so a new sync starts here.
+	if pToken.Token == "" {
+		o.apiClientsById = nil
+		o.userRoles = nil
+		o.rolesById = nil
+	}
+	if o.rolesById == nil {
+		o.rolesById = make(map[string]*rolestore.Role)
 	}
 
 	roleResources := make([]*v2.Resource, 0)
 	for _, role := range privXRoles {
 		roleCopy := role
+		o.rolesById[role.ID] = &roleCopy
 		newResource, err := roleResource(ctx, &roleCopy)
 		if err != nil {
 			return nil, "", nil, err
@@ -64,7 +104,7 @@ func (o *roleBuilder) List(
 		roleResources = append(roleResources, newResource)
 	}
 
-	return roleResources, nextToken, nil, nil
+	return roleResources, nextToken, outputAnnotations, nil
 }
 
 func (o *roleBuilder) Entitlements(
@@ -76,7 +116,7 @@ func (o *roleBuilder) Entitlements(
 		entitlement.NewAssignmentEntitlement(
 			resource,
 			EntitlementAssigned,
-			entitlement.WithGrantableTo(roleResourceType),
+			entitlement.WithGrantableTo(roleResourceType, userResourceType, sourceResourceType, groupResourceType),
 			entitlement.WithDescription(fmt.Sprintf("Has %s role membership", resource.DisplayName)),
 			entitlement.WithDisplayName(fmt.Sprintf("%s role %s", resource.DisplayName, EntitlementAssigned)),
 		),
@@ -100,58 +140,194 @@ func (o *roleBuilder) Grants(
 		logger.Error("invalid page token", zap.Error(err))
 	}
 
-	privXUsers, nextToken, err := o.client.GetUsersForRole(
+	privXUsers, nextToken, rateLimit, err := o.client.GetUsersForRole(
 		ctx,
 		resource.Id.Resource,
 		offset,
 		limit,
 	)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", outputAnnotations, err
+	}
+
+	// API clients may not be listed as role members, so their roles are read
+	// from the API clients themselves.
+	if o.apiClientsById == nil {
+		apiClientsById, apiClientsRateLimit, err := getAPIClientsById(ctx, o.client)
+		if apiClientsRateLimit != nil {
+			outputAnnotations = rateLimitAnnotations(apiClientsRateLimit)
+		}
+		if err != nil {
+			return nil, "", outputAnnotations, err
+		}
+		o.apiClientsById = apiClientsById
 	}
 
 	var roleAssignments []*v2.Grant
+	if pToken.Token == "" {
+		// Source rules are only listed along with the first page of members.
+		role, ok := o.rolesById[resource.Id.Resource]
+		if !ok {
+			var roleRateLimit *v2.RateLimitDescription
+			role, roleRateLimit, err = o.client.GetRole(ctx, resource.Id.Resource)
+			if roleRateLimit != nil {
+				outputAnnotations = rateLimitAnnotations(roleRateLimit)
+			}
+			if err != nil {
+				return nil, "", outputAnnotations, err
+			}
+		}
+		roleAssignments = append(roleAssignments, sourceRuleGrants(resource, role)...)
+		roleAssignments = append(roleAssignments, apiClientRoleGrants(resource, o.apiClientsById)...)
+	}
+
 	for _, user := range privXUsers {
-		roleAssignments = append(
-			roleAssignments,
-			grant.NewGrant(
-				resource,
-				EntitlementAssigned,
-				&v2.ResourceId{
-					ResourceType: userResourceType.Id,
-					Resource:     user.ID,
-				},
-			),
-		)
+		// API clients holding the role were granted it above already.
+		apiClient := o.apiClientsById[user.ID]
+		if apiClient != nil && slices.Contains(apiClientRoleIds(apiClient), resource.Id.Resource) {
+			continue
+		}
+
+		userCopy := user
+		membership := userRoleMembership(&userCopy, resource.Id.Resource)
+		if membership == nil {
+			membership, rateLimit, err = o.memberRole(ctx, user.ID, resource.Id.Resource)
+			if rateLimit != nil {
+				outputAnnotations = rateLimitAnnotations(rateLimit)
+			}
+			if err != nil {
+				return nil, "", outputAnnotations, err
+			}
+		}
+		roleAssignments = append(roleAssignments, roleGrant(resource, user.ID, membership))
 	}
 
-	return roleAssignments, nextToken, nil, nil
+	return roleAssignments, nextToken, outputAnnotations, nil
 }
 
+// Grant assigns the role to a user, or maps the users of a source to it
+// through the role's source rules. It returns the grant as PrivX reports it
+// after the write, so that its metadata is known without waiting for the next
+// sync.
 func (o *roleBuilder) Grant(
 	ctx context.Context,
 	principal *v2.Resource,
 	entitlement *v2.Entitlement,
-) (annotations.Annotations, error) {
+) ([]*v2.Grant, annotations.Annotations, error) {
 	logger := ctxzap.Extract(ctx)
 
-	if principal.Id.ResourceType != userResourceType.Id {
+	switch principal.Id.ResourceType {
+	case userResourceType.Id:
+		return o.grantUser(ctx, principal, entitlement)
+	case sourceResourceType.Id:
+		searchString, err := requestedSearchString(entitlement)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o.grantSourceRule(ctx, entitlement, principal.Id.Resource, searchString)
+	case groupResourceType.Id:
+		sourceId, searchString, err := parseGroupResourceId(principal.Id.Resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o.grantSourceRule(ctx, entitlement, sourceId, searchString)
+	default:
 		logger.Warn(
-			"baton-privx: only users can be assigned roles",
+			"baton-privx: only users, sources and groups can be assigned roles",
 			zap.String("principal_type", principal.Id.ResourceType),
 			zap.String("principal_id", principal.Id.Resource),
 		)
-		return nil, fmt.Errorf("baton-privx: only users can be assigned roles")
+		return nil, nil, status.Error(codes.InvalidArgument, "baton-privx: only users, sources and groups can be assigned roles")
+	}
+}
+
+func (o *roleBuilder) grantUser(
+	ctx context.Context,
+	principal *v2.Resource,
+	entitlement *v2.Entitlement,
+) ([]*v2.Grant, annotations.Annotations, error) {
+	grantEnd, err := requestedGrantEnd(entitlement)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if grantEnd.IsZero() {
+		err = o.client.GrantRole(
+			ctx,
+			principal.Id.Resource,
+			entitlement.Resource.Id.Resource,
+		)
+	} else {
+		err = o.client.GrantRoleUntil(
+			ctx,
+			principal.Id.Resource,
+			entitlement.Resource.Id.Resource,
+			time.Now(),
+			grantEnd,
+		)
+	}
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err := o.client.GrantRole(
+	membership, err := o.client.GetUserRole(
 		ctx,
 		principal.Id.Resource,
 		entitlement.Resource.Id.Resource,
 	)
-	return nil, err
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*v2.Grant{
+		roleGrant(entitlement.Resource, principal.Id.Resource, membership),
+	}, nil, nil
 }
 
+// grantSourceRule adds a source rule clause for the users of a source
+// matching searchString to the role. The grant is to the source itself if
+// searchString is empty, or to the directory group it selects otherwise.
+func (o *roleBuilder) grantSourceRule(
+	ctx context.Context,
+	entitlement *v2.Entitlement,
+	sourceId string,
+	searchString string,
+) ([]*v2.Grant, annotations.Annotations, error) {
+	err := o.client.AddSourceRule(
+		ctx,
+		entitlement.Resource.Id.Resource,
+		sourceId,
+		searchString,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role, rateLimit, err := o.client.GetRole(ctx, entitlement.Resource.Id.Resource)
+	if err != nil {
+		return nil, rateLimitAnnotations(rateLimit), err
+	}
+
+	principalId := sourceId
+	if searchString != "" {
+		principalId = groupResourceId(sourceId, searchString)
+	}
+
+	var grants []*v2.Grant
+	for _, sourceGrant := range sourceRuleGrants(entitlement.Resource, role) {
+		if sourceGrant.Principal.Id.Resource == principalId {
+			grants = append(grants, sourceGrant)
+		}
+	}
+
+	return grants, rateLimitAnnotations(rateLimit), nil
+}
+
+// Revoke removes a user's explicit role membership, or the source rule that
+// maps a source or group to the role. If enabled, a user whose role was
+// revoked also has their sessions terminated. Users mapped through a source
+// rule aren't known here and keep theirs until they expire.
 func (o *roleBuilder) Revoke(
 	ctx context.Context,
 	grant *v2.Grant,
@@ -161,37 +337,374 @@ func (o *roleBuilder) Revoke(
 	entitlement := grant.Entitlement
 	principal := grant.Principal
 
-	if principal.Id.ResourceType != userResourceType.Id {
+	var err error
+	switch principal.Id.ResourceType {
+	case userResourceType.Id:
+		err = o.client.RevokeRole(
+			ctx,
+			principal.Id.Resource,
+			entitlement.Resource.Id.Resource,
+		)
+	case sourceResourceType.Id:
+		err = o.client.RemoveSourceRules(
+			ctx,
+			entitlement.Resource.Id.Resource,
+			principal.Id.Resource,
+			"",
+		)
+	case groupResourceType.Id:
+		sourceId, searchString, parseErr := parseGroupResourceId(principal.Id.Resource)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		err = o.client.RemoveSourceRules(
+			ctx,
+			entitlement.Resource.Id.Resource,
+			sourceId,
+			searchString,
+		)
+	default:
 		logger.Warn(
-			"baton-privx: only users can have role assignment revoked",
+			"baton-privx: only users, sources and groups can have role assignment revoked",
 			zap.String("principal_type", principal.Id.ResourceType),
 			zap.String("principal_id", principal.Id.Resource),
 		)
-		return nil, fmt.Errorf("baton-privx: only users can have role assignment revoked")
+		return nil, status.Error(codes.InvalidArgument, "baton-privx: only users, sources and groups can have role assignment revoked")
 	}
 
-	err := o.client.RevokeRole(
-		ctx,
-		principal.Id.Resource,
-		entitlement.Resource.Id.Resource,
+	switch {
+	case errors.Is(err, client.ErrImplicitRoleMembership):
+		logger.Warn(
+			"baton-privx: role membership is implicit and must be removed in the directory",
+			zap.String("principal_id", principal.Id.Resource),
+			zap.String("role_id", entitlement.Resource.Id.Resource),
+		)
+		return nil, err
+	case status.Code(err) == codes.NotFound:
+		// The principal or the role is already gone, so the grant is too.
+		logger.Info(
+			"baton-privx: principal or role not found, treating role assignment as revoked",
+			zap.String("principal_id", principal.Id.Resource),
+			zap.String("role_id", entitlement.Resource.Id.Resource),
+			zap.Error(err),
+		)
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if o.terminateSessions && principal.Id.ResourceType == userResourceType.Id {
+		err = o.client.TerminateUserSessions(ctx, principal.Id.Resource)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return nil, fmt.Errorf(
+				"baton-privx: revoked role %s but failed to terminate the sessions of user %s: %w",
+				entitlement.Resource.Id.Resource,
+				principal.Id.Resource,
+				err,
+			)
+		}
+	}
+	return nil, nil
+}
+
+// roleAssignedEntitlementID returns the ID of the `assigned` entitlement of
+// the given role, used to expand grants held by a role to its members.
+func roleAssignedEntitlementID(roleId string) string {
+	return entitlement.NewEntitlementID(
+		&v2.Resource{
+			Id: &v2.ResourceId{
+				ResourceType: roleResourceType.Id,
+				Resource:     roleId,
+			},
+		},
+		EntitlementAssigned,
 	)
-	return nil, err
 }
 
-func newRoleBuilder(client client.PrivXClient) *roleBuilder {
-	return &roleBuilder{client: client}
+// requestedGrantEnd returns the expiry requested for a grant through a
+// GrantMetadata annotation on the entitlement, either as an absolute RFC 3339
+// `grant_end` or as a relative `grant_duration` (e.g. "8h"). A zero time means
+// the grant should be permanent. A grant end that has already passed is
+// rejected.
+func requestedGrantEnd(entitlement *v2.Entitlement) (time.Time, error) {
+	metadata := &v2.GrantMetadata{}
+	entitlementAnnotations := annotations.Annotations(entitlement.Annotations)
+	ok, err := entitlementAnnotations.Pick(metadata)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !ok || metadata.Metadata == nil {
+		return time.Time{}, nil
+	}
+
+	fields := metadata.Metadata.GetFields()
+	if value, ok := fields[grantEndKey]; ok {
+		grantEnd, err := time.Parse(time.RFC3339, value.GetStringValue())
+		if err != nil {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "baton-privx: invalid %s: %v", grantEndKey, err)
+		}
+		if !grantEnd.After(time.Now()) {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "baton-privx: %s must be in the future", grantEndKey)
+		}
+		return grantEnd, nil
+	}
+	if value, ok := fields[grantDurationKey]; ok {
+		duration, err := time.ParseDuration(value.GetStringValue())
+		if err != nil {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "baton-privx: invalid %s: %v", grantDurationKey, err)
+		}
+		if duration <= 0 {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "baton-privx: %s must be positive", grantDurationKey)
+		}
+		return time.Now().Add(duration), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// roleGrant returns the grant of the role's `assigned` entitlement to a user.
+// The membership, if known, is attached as grant metadata.
+func roleGrant(role *v2.Resource, userId string, membership *rolestore.Role) *v2.Grant {
+	var grantOptions []grant.GrantOption
+	if membership != nil {
+		grantOptions = append(grantOptions, grant.WithGrantMetadata(roleGrantMetadata(membership)))
+		// Implicit memberships come from the role's source rules and can't
+		// be revoked per user.
+		if membership.Implicit && !membership.Explicit {
+			grantOptions = append(grantOptions, grant.WithAnnotation(&v2.GrantImmutable{}))
+		}
+	}
+
+	return grant.NewGrant(
+		role,
+		EntitlementAssigned,
+		&v2.ResourceId{
+			ResourceType: userResourceType.Id,
+			Resource:     userId,
+		},
+		grantOptions...,
+	)
+}
+
+// sourceRuleGrants returns a grant of the role's `assigned` entitlement for
+// each clause of its source rules that grants the role on its own. Clauses
+// mapping a whole source are granted to the source, the others to the
+// directory group they select, expandable to the group's members. Clauses
+// combined with others by ALL only grant the role to the users matching all
+// of them, which no single source or group stands for, so they are left out.
+func sourceRuleGrants(resource *v2.Resource, role *rolestore.Role) []*v2.Grant {
+	var grants []*v2.Grant
+	granted := make(map[string]bool)
+	for _, leaf := range client.SourceRuleGrantedLeaves(role.SourceRule) {
+		if leaf.Source == "" {
+			continue
+		}
+
+		principalId := &v2.ResourceId{
+			ResourceType: sourceResourceType.Id,
+			Resource:     leaf.Source,
+		}
+		grantOptions := []grant.GrantOption{
+			grant.WithGrantMetadata(map[string]interface{}{
+				"source_rule":   true,
+				"source":        leaf.Source,
+				"search_string": leaf.Pattern,
+			}),
+		}
+		if leaf.Pattern != "" {
+			principalId = &v2.ResourceId{
+				ResourceType: groupResourceType.Id,
+				Resource:     groupResourceId(leaf.Source, leaf.Pattern),
+			}
+			grantOptions = append(
+				grantOptions,
+				grant.WithAnnotation(
+					&v2.GrantExpandable{
+						EntitlementIds: []string{groupMemberEntitlementID(principalId.Resource)},
+					},
+				),
+			)
+		}
+
+		key := principalId.ResourceType + "/" + principalId.Resource
+		if granted[key] {
+			continue
+		}
+		granted[key] = true
+
+		grants = append(grants, grant.NewGrant(resource, EntitlementAssigned, principalId, grantOptions...))
+	}
+
+	return grants
+}
+
+// apiClientRoleGrants returns a grant of the role's `assigned` entitlement to
+// each API client holding the role. API clients are synced as users.
+func apiClientRoleGrants(resource *v2.Resource, apiClientsById map[string]*client.APIClient) []*v2.Grant {
+	apiClientIds := make([]string, 0, len(apiClientsById))
+	for id, apiClient := range apiClientsById {
+		if slices.Contains(apiClientRoleIds(apiClient), resource.Id.Resource) {
+			apiClientIds = append(apiClientIds, id)
+		}
+	}
+	slices.Sort(apiClientIds)
+
+	grants := make([]*v2.Grant, 0, len(apiClientIds))
+	for _, id := range apiClientIds {
+		grants = append(
+			grants,
+			grant.NewGrant(
+				resource,
+				EntitlementAssigned,
+				&v2.ResourceId{
+					ResourceType: userResourceType.Id,
+					Resource:     id,
+				},
+				grant.WithGrantMetadata(map[string]interface{}{
+					"explicit":   true,
+					"implicit":   false,
+					"api_client": true,
+				}),
+			),
+		)
+	}
+
+	return grants
+}
+
+// requestedSearchString returns the search string requested for a source
+// grant through a GrantMetadata annotation on the entitlement, or "" to map
+// all the users of the source.
+func requestedSearchString(entitlement *v2.Entitlement) (string, error) {
+	metadata := &v2.GrantMetadata{}
+	entitlementAnnotations := annotations.Annotations(entitlement.Annotations)
+	ok, err := entitlementAnnotations.Pick(metadata)
+	if err != nil {
+		return "", err
+	}
+	if !ok || metadata.Metadata == nil {
+		return "", nil
+	}
+
+	return metadata.Metadata.GetFields()[searchStringKey].GetStringValue(), nil
+}
+
+// userRoleMembership returns the user's membership entry for the given role,
+// or nil if PrivX didn't include the user's roles in the response.
+func userRoleMembership(user *rolestore.User, roleId string) *rolestore.Role {
+	for i := range user.Roles {
+		if user.Roles[i].ID == roleId {
+			return &user.Roles[i]
+		}
+	}
+
+	return nil
+}
+
+// memberRole returns a role member's membership entry for the role, read
+// from the user's own role list, or nil if the user isn't found there.
+func (o *roleBuilder) memberRole(
+	ctx context.Context,
+	userId string,
+	roleId string,
+) (*rolestore.Role, *v2.RateLimitDescription, error) {
+	if o.userRoles == nil {
+		o.userRoles = make(map[string][]rolestore.Role)
+	}
+
+	var rateLimit *v2.RateLimitDescription
+	roles, ok := o.userRoles[userId]
+	if !ok {
+		var err error
+		roles, rateLimit, err = o.client.GetUserRoles(ctx, userId)
+		if status.Code(err) == codes.NotFound {
+			// The user was removed since the member listing.
+			return nil, rateLimit, nil
+		}
+		if err != nil {
+			return nil, rateLimit, err
+		}
+		o.userRoles[userId] = roles
+	}
+
+	return userRoleMembership(&rolestore.User{Roles: roles}, roleId), rateLimit, nil
 }
 
+// roleGrantMetadata returns whether a role membership is explicit or implicit
+// (matched by the role's directory source rules) along with its grant type and
+// validity window.
+func roleGrantMetadata(membership *rolestore.Role) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"explicit": membership.Explicit,
+		"implicit": membership.Implicit,
+	}
+	if membership.GrantType != "" {
+		metadata["grant_type"] = membership.GrantType
+	}
+	if membership.GrantStart != "" {
+		metadata["grant_start"] = membership.GrantStart
+	}
+	if membership.GrantEnd != "" {
+		metadata["grant_end"] = membership.GrantEnd
+	}
+	if len(membership.GrantValidityPeriods) > 0 {
+		periods := make([]interface{}, 0, len(membership.GrantValidityPeriods))
+		for _, period := range membership.GrantValidityPeriods {
+			periods = append(periods, map[string]interface{}{
+				"grant_start": period.GrantStart,
+				"grant_end":   period.GrantEnd,
+			})
+		}
+		metadata["grant_validity_periods"] = periods
+	}
+	if membership.FloatingLength > 0 {
+		metadata["floating_length"] = membership.FloatingLength
+	}
+
+	return metadata
+}
+
+func newRoleBuilder(client client.PrivXClient, terminateSessions bool) *roleBuilder {
+	return &roleBuilder{
+		client:            client,
+		terminateSessions: terminateSessions,
+	}
+}
+
+// roleResource Converts a PrivX Role into a ConductorOne Resource.
 func roleResource(ctx context.Context, role *rolestore.Role) (*v2.Resource, error) {
+	sourceRules, err := json.Marshal(role.SourceRule)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := map[string]interface{}{
+		"name":            role.Name,
+		"comment":         role.Comment,
+		"grant_type":      role.GrantType,
+		"system":          role.System,
+		"permit_agent":    role.PermitAgent,
+		"access_group_id": role.AccessGroupID,
+		"member_count":    role.MemberCount,
+		"floating_length": role.FloatingLength,
+		"source_rules":    string(sourceRules),
+	}
+	if role.Context != nil {
+		profile["context_enabled"] = role.Context.Enabled
+		profile["context_block_role"] = role.Context.BlockRole
+		profile["context_start_time"] = role.Context.StartTime
+		profile["context_end_time"] = role.Context.EndTime
+		profile["context_timezone"] = role.Context.Timezone
+	}
+
 	createdResource, err := resource.NewRoleResource(
 		role.Name,
 		roleResourceType,
 		role.ID,
 		[]resource.RoleTraitOption{
-			resource.WithRoleProfile(map[string]interface{}{
-				"name": role.Name,
-			}),
+			resource.WithRoleProfile(profile),
 		},
+		resource.WithDescription(role.Comment),
 	)
 	if err != nil {
 		return nil, err
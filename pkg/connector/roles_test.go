@@ -0,0 +1,653 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	resourceSdk "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func entitlementWithGrantMetadata(t *testing.T, metadata map[string]interface{}) *v2.Entitlement {
+	md, err := structpb.NewStruct(metadata)
+	require.Nil(t, err)
+
+	return &v2.Entitlement{
+		Annotations: annotations.New(&v2.GrantMetadata{Metadata: md}),
+	}
+}
+
+func TestRequestedGrantEnd(t *testing.T) {
+	t.Run("should be permanent without metadata", func(t *testing.T) {
+		grantEnd, err := requestedGrantEnd(&v2.Entitlement{})
+		require.Nil(t, err)
+		require.True(t, grantEnd.IsZero())
+	})
+
+	t.Run("should parse an absolute grant end", func(t *testing.T) {
+		grantEnd, err := requestedGrantEnd(
+			entitlementWithGrantMetadata(t, map[string]interface{}{
+				"grant_end": "2030-01-02T15:04:05Z",
+			}),
+		)
+		require.Nil(t, err)
+		require.Equal(t, time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC), grantEnd.UTC())
+	})
+
+	t.Run("should parse a relative grant duration", func(t *testing.T) {
+		before := time.Now()
+		grantEnd, err := requestedGrantEnd(
+			entitlementWithGrantMetadata(t, map[string]interface{}{
+				"grant_duration": "8h",
+			}),
+		)
+		require.Nil(t, err)
+		require.WithinDuration(t, before.Add(8*time.Hour), grantEnd, time.Minute)
+	})
+
+	t.Run("should reject an invalid duration", func(t *testing.T) {
+		_, err := requestedGrantEnd(
+			entitlementWithGrantMetadata(t, map[string]interface{}{
+				"grant_duration": "-1h",
+			}),
+		)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("should reject a grant end in the past", func(t *testing.T) {
+		_, err := requestedGrantEnd(
+			entitlementWithGrantMetadata(t, map[string]interface{}{
+				"grant_end": "2020-01-02T15:04:05Z",
+			}),
+		)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestRolesRevokeImplicit(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				require.NotEqual(t, http.MethodPut, request.Method)
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/user_roles_implicit.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+
+	_, err = roleBuilder.Revoke(ctx, &v2.Grant{
+		Entitlement: &v2.Entitlement{
+			Resource: &v2.Resource{
+				Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: "3453395a-2a12-50a5-4fdb-794d567edae0"},
+			},
+		},
+		Principal: &v2.Resource{
+			Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"},
+		},
+	})
+	require.True(t, errors.Is(err, client.ErrImplicitRoleMembership))
+}
+
+func TestRolesRevokeTerminatesSessions(t *testing.T) {
+	ctx := context.Background()
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+	var putRoles []rolestore.Role
+	terminated := false
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && request.Method == http.MethodPut:
+					require.Nil(t, json.NewDecoder(request.Body).Decode(&putRoles))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+					roles := []rolestore.Role{{ID: roleId, Explicit: true}}
+					if putRoles != nil {
+						roles = putRoles
+					}
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{"items": roles})
+				case request.URL.Path == "/auth/api/v1/sessionstorage/users/"+userId+"/sessions/terminate":
+					terminated = true
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, true)
+
+	_, err = roleBuilder.Revoke(ctx, &v2.Grant{
+		Entitlement: &v2.Entitlement{
+			Resource: &v2.Resource{
+				Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+			},
+		},
+		Principal: &v2.Resource{
+			Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId},
+		},
+	})
+	require.Nil(t, err)
+	require.NotNil(t, putRoles)
+	require.Len(t, putRoles, 0)
+	require.True(t, terminated)
+}
+
+func TestRolesGrant(t *testing.T) {
+	ctx := context.Background()
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+	var putRoles []rolestore.Role
+	puts := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && request.Method == http.MethodPut:
+					puts++
+					require.Nil(t, json.NewDecoder(request.Body).Decode(&putRoles))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && putRoles != nil:
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{"items": putRoles})
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+					// The user only holds the role through its source rules.
+					json, err := os.ReadFile("./client/fixtures/user_roles_implicit.json")
+					require.Nil(t, err)
+					_, _ = writer.Write(json)
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+
+	role := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+	}
+	grants, _, err := roleBuilder.Grant(
+		ctx,
+		&v2.Resource{
+			Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId},
+		},
+		&v2.Entitlement{Resource: role},
+	)
+	require.Nil(t, err)
+
+	// The implicit membership is made explicit rather than taken as granted.
+	require.Len(t, putRoles, 1)
+	require.Equal(t, roleId, putRoles[0].ID)
+	require.True(t, putRoles[0].Explicit)
+
+	require.Len(t, grants, 1)
+	require.Equal(t, "role:3453395a-2a12-50a5-4fdb-794d567edae0:assigned", grants[0].Entitlement.Id)
+	require.Equal(t, userId, grants[0].Principal.Id.Resource)
+
+	grantAnnotations := annotations.Annotations(grants[0].Annotations)
+	metadata := &v2.GrantMetadata{}
+	ok, err := grantAnnotations.Pick(metadata)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.True(t, metadata.Metadata.GetFields()["explicit"].GetBoolValue())
+	require.True(t, metadata.Metadata.GetFields()["implicit"].GetBoolValue())
+	require.False(t, grantAnnotations.Contains(&v2.GrantImmutable{}))
+
+	// Granting it again writes nothing.
+	_, _, err = roleBuilder.Grant(
+		ctx,
+		&v2.Resource{
+			Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId},
+		},
+		&v2.Entitlement{Resource: role},
+	)
+	require.Nil(t, err)
+	require.Equal(t, 1, puts)
+}
+
+func TestSourceRuleGrants(t *testing.T) {
+	role := &rolestore.Role{
+		ID: "3453395a-2a12-50a5-4fdb-794d567edae0",
+		SourceRule: rolestore.SourceRule{
+			Type:  "GROUP",
+			Match: "ANY",
+			Rules: []rolestore.SourceRule{
+				{Type: "RULE", Source: "a1ee631f-9698-4a77-8042-2002602f9d08", Pattern: "(principal=c1privxadmin)"},
+				{
+					Type:  "GROUP",
+					Match: "ALL",
+					Rules: []rolestore.SourceRule{
+						{Type: "RULE", Source: "a1ee631f-9698-4a77-8042-2002602f9d08"},
+					},
+				},
+			},
+		},
+	}
+	resource := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: role.ID},
+	}
+
+	grants := sourceRuleGrants(resource, role)
+	require.Len(t, grants, 2)
+
+	require.Equal(t, groupResourceType.Id, grants[0].Principal.Id.ResourceType)
+	require.Equal(
+		t,
+		groupResourceId("a1ee631f-9698-4a77-8042-2002602f9d08", "(principal=c1privxadmin)"),
+		grants[0].Principal.Id.Resource,
+	)
+	grantAnnotations := annotations.Annotations(grants[0].Annotations)
+	expandable := &v2.GrantExpandable{}
+	ok, err := grantAnnotations.Pick(expandable)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{groupMemberEntitlementID(grants[0].Principal.Id.Resource)}, expandable.EntitlementIds)
+
+	require.Equal(t, sourceResourceType.Id, grants[1].Principal.Id.ResourceType)
+	require.Equal(t, "a1ee631f-9698-4a77-8042-2002602f9d08", grants[1].Principal.Id.Resource)
+
+	t.Run("should not grant clauses combined by ALL", func(t *testing.T) {
+		role := &rolestore.Role{
+			ID: "3453395a-2a12-50a5-4fdb-794d567edae0",
+			SourceRule: rolestore.SourceRule{
+				Type:  "GROUP",
+				Match: "ANY",
+				Rules: []rolestore.SourceRule{
+					{Type: "RULE", Source: "a1ee631f-9698-4a77-8042-2002602f9d08", Pattern: "(memberOf=admins)"},
+					{
+						Type:  "GROUP",
+						Match: "ALL",
+						Rules: []rolestore.SourceRule{
+							{Type: "RULE", Source: "a1ee631f-9698-4a77-8042-2002602f9d08", Pattern: "(memberOf=ops)"},
+							{Type: "RULE", Source: "a1ee631f-9698-4a77-8042-2002602f9d08", Pattern: "(memberOf=oncall)"},
+						},
+					},
+				},
+			},
+		}
+
+		grants := sourceRuleGrants(resource, role)
+		require.Len(t, grants, 1)
+		require.Equal(
+			t,
+			groupResourceId("a1ee631f-9698-4a77-8042-2002602f9d08", "(memberOf=admins)"),
+			grants[0].Principal.Id.Resource,
+		)
+	})
+}
+
+func TestRolesList(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/roles_page_0.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+
+	resources, token, _, err := roleBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+	require.Len(t, resources, 3)
+	require.Equal(t, "", token)
+
+	admin := resources[1]
+	require.Equal(t, "PrivX Administrators", admin.Description)
+
+	roleTrait, err := resourceSdk.GetRoleTrait(admin)
+	require.Nil(t, err)
+	require.True(t, roleTrait.Profile.GetFields()["system"].GetBoolValue())
+	require.Equal(
+		t,
+		"fcde7572-9781-4d47-bc1d-7977afb11dc3",
+		roleTrait.Profile.GetFields()["access_group_id"].GetStringValue(),
+	)
+	require.Contains(t, roleTrait.Profile.GetFields()["source_rules"].GetStringValue(), "(principal=c1privxadmin)")
+}
+
+func TestRolesGrantsListedSourceRules(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRoleStore(t)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+
+	resources, _, _, err := roleBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+
+	admin := resources[1]
+	grants, _, _, err := roleBuilder.Grants(ctx, admin, &pagination.Token{})
+	require.Nil(t, err)
+	require.Len(t, grants, 1)
+	require.Equal(t, groupResourceType.Id, grants[0].Principal.Id.ResourceType)
+
+	// The source rules come with the listed roles.
+	require.Equal(t, 0, fake.roleReads)
+
+	t.Run("should fetch roles that weren't listed", func(t *testing.T) {
+		roleBuilder := newRoleBuilder(*privXClient, false)
+
+		grants, _, _, err := roleBuilder.Grants(ctx, admin, &pagination.Token{})
+		require.Nil(t, err)
+		require.Len(t, grants, 1)
+		require.Equal(t, 1, fake.roleReads)
+	})
+}
+
+func TestRolesGrantUntil(t *testing.T) {
+	ctx := context.Background()
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+	var putRoles []rolestore.Role
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles" && request.Method == http.MethodPut:
+					require.Nil(t, json.NewDecoder(request.Body).Decode(&putRoles))
+				case request.URL.Path == "/role-store/api/v1/users/"+userId+"/roles":
+					roles := putRoles
+					if roles == nil {
+						roles = []rolestore.Role{}
+					}
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{"items": roles})
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+
+	entitlement := entitlementWithGrantMetadata(t, map[string]interface{}{
+		"grant_end": "2030-01-02T15:04:05Z",
+	})
+	entitlement.Resource = &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+	}
+	grants, _, err := roleBuilder.Grant(
+		ctx,
+		&v2.Resource{
+			Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId},
+		},
+		entitlement,
+	)
+	require.Nil(t, err)
+
+	// The role is written as time restricted with a single validity window.
+	require.Len(t, putRoles, 1)
+	require.Equal(t, roleId, putRoles[0].ID)
+	require.True(t, putRoles[0].Explicit)
+	require.Equal(t, client.GrantTypeTimeRestricted, putRoles[0].GrantType)
+	require.Equal(t, "2030-01-02T15:04:05Z", putRoles[0].GrantEnd)
+	require.Len(t, putRoles[0].GrantValidityPeriods, 1)
+	require.Equal(t, putRoles[0].GrantStart, putRoles[0].GrantValidityPeriods[0].GrantStart)
+	require.Equal(t, "2030-01-02T15:04:05Z", putRoles[0].GrantValidityPeriods[0].GrantEnd)
+
+	// The returned grant carries the validity as PrivX reports it.
+	require.Len(t, grants, 1)
+	grantAnnotations := annotations.Annotations(grants[0].Annotations)
+	metadata := &v2.GrantMetadata{}
+	ok, err := grantAnnotations.Pick(metadata)
+	require.Nil(t, err)
+	require.True(t, ok)
+	fields := metadata.Metadata.GetFields()
+	require.Equal(t, client.GrantTypeTimeRestricted, fields["grant_type"].GetStringValue())
+	require.Equal(t, "2030-01-02T15:04:05Z", fields["grant_end"].GetStringValue())
+	require.Len(t, fields["grant_validity_periods"].GetListValue().GetValues(), 1)
+	require.False(t, grantAnnotations.Contains(&v2.GrantImmutable{}))
+}
+
+func TestRolesGrantsAPIClients(t *testing.T) {
+	ctx := context.Background()
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+	apiClientId := "bc0e7972-8f4f-43a0-795a-abfa0ed0309a"
+	userId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	apiClientRequests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch request.URL.Path {
+				case "/local-user-store/api/v1/api-clients":
+					apiClientRequests++
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+						"items": []client.APIClient{
+							{ID: apiClientId, Name: "deploy-bot", Roles: []rolestore.RoleRef{{ID: roleId}}},
+						},
+					})
+				case "/role-store/api/v1/roles/" + roleId + "/members":
+					// PrivX may list an API client as a role member too.
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+						"items": []rolestore.User{{ID: apiClientId}, {ID: userId}},
+					})
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+	resource := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+	}
+
+	grants, _, _, err := roleBuilder.Grants(ctx, resource, &pagination.Token{})
+	require.Nil(t, err)
+
+	// The API client is granted the role once, as an API client.
+	require.Len(t, grants, 2)
+	require.Equal(t, apiClientId, grants[0].Principal.Id.Resource)
+	grantAnnotations := annotations.Annotations(grants[0].Annotations)
+	metadata := &v2.GrantMetadata{}
+	ok, err := grantAnnotations.Pick(metadata)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.True(t, metadata.Metadata.AsMap()["api_client"].(bool))
+	require.Equal(t, userId, grants[1].Principal.Id.Resource)
+
+	// API clients are listed once per sync, not once per role.
+	_, _, _, err = roleBuilder.Grants(ctx, resource, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, 1, apiClientRequests)
+
+	// A new sync starts by listing roles and lists them again.
+	_, _, _, err = roleBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+	_, _, _, err = roleBuilder.Grants(ctx, resource, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, 2, apiClientRequests)
+}
+
+func TestRolesGrantsMemberships(t *testing.T) {
+	ctx := context.Background()
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+	explicitUserId := "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b"
+	implicitUserId := "e2401730-dc81-4f6e-864a-328c3c8e8f6b"
+	userRolesRequests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				var fixture string
+				switch request.URL.Path {
+				case "/role-store/api/v1/roles/" + roleId + "/members":
+					// Members are listed without their roles.
+					fixture = "./client/fixtures/role_members_page_0.json"
+				case "/role-store/api/v1/users/" + implicitUserId + "/roles":
+					userRolesRequests++
+					fixture = "./client/fixtures/user_roles_implicit.json"
+				case "/role-store/api/v1/users/" + explicitUserId + "/roles":
+					userRolesRequests++
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+						"items": []rolestore.Role{
+							{ID: roleId, Explicit: true, GrantType: client.GrantTypeTimeRestricted, GrantEnd: "2030-01-02T15:04:05Z"},
+						},
+					})
+					return
+				default:
+					_, _ = writer.Write([]byte(`{}`))
+					return
+				}
+				body, err := os.ReadFile(fixture)
+				require.Nil(t, err)
+				_, _ = writer.Write(body)
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	roleBuilder := newRoleBuilder(*privXClient, false)
+	resource := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+	}
+
+	grants, _, _, err := roleBuilder.Grants(ctx, resource, &pagination.Token{})
+	require.Nil(t, err)
+	require.Len(t, grants, 2)
+
+	grantMetadata := func(grant *v2.Grant) (map[string]interface{}, bool) {
+		grantAnnotations := annotations.Annotations(grant.Annotations)
+		metadata := &v2.GrantMetadata{}
+		ok, err := grantAnnotations.Pick(metadata)
+		require.Nil(t, err)
+		require.True(t, ok)
+		return metadata.Metadata.AsMap(), grantAnnotations.Contains(&v2.GrantImmutable{})
+	}
+
+	require.Equal(t, explicitUserId, grants[0].Principal.Id.Resource)
+	metadata, immutable := grantMetadata(grants[0])
+	require.Equal(t, true, metadata["explicit"])
+	require.Equal(t, false, metadata["implicit"])
+	require.Equal(t, client.GrantTypeTimeRestricted, metadata["grant_type"])
+	require.Equal(t, "2030-01-02T15:04:05Z", metadata["grant_end"])
+	require.False(t, immutable)
+
+	// Members matched by the role's source rules can't be revoked per user.
+	require.Equal(t, implicitUserId, grants[1].Principal.Id.Resource)
+	metadata, immutable = grantMetadata(grants[1])
+	require.Equal(t, false, metadata["explicit"])
+	require.Equal(t, true, metadata["implicit"])
+	require.True(t, immutable)
+
+	// Each member's roles are read once per sync.
+	_, _, _, err = roleBuilder.Grants(ctx, resource, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, 2, userRolesRequests)
+}
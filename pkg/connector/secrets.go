@@ -0,0 +1,221 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	EntitlementRead  = client.SecretAccessRead
+	EntitlementWrite = client.SecretAccessWrite
+)
+
+type secretBuilder struct {
+	client client.PrivXClient
+}
+
+func (o *secretBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return secretResourceType
+}
+
+// List returns all the secrets of the PrivX Vault as resource objects. Only
+// their metadata is synced, never their values.
+func (o *secretBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	offset, limit, err := parsePageToken(pToken)
+	if err != nil {
+		logger.Error("invalid page token", zap.Error(err))
+	}
+
+	privXSecrets, nextToken, rateLimit, err := o.client.GetSecrets(ctx, offset, limit)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching secrets", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	secretResources := make([]*v2.Resource, 0)
+	for _, secret := range privXSecrets {
+		secretCopy := secret
+		newResource, err := secretResource(ctx, &secretCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		secretResources = append(secretResources, newResource)
+	}
+
+	return secretResources, nextToken, outputAnnotations, nil
+}
+
+// Entitlements returns the `read` and `write` entitlements of a secret, which
+// the vault grants to roles.
+func (o *secretBuilder) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlements := make([]*v2.Entitlement, 0, 2)
+	for _, access := range []string{EntitlementRead, EntitlementWrite} {
+		entitlements = append(
+			entitlements,
+			entitlement.NewPermissionEntitlement(
+				resource,
+				access,
+				entitlement.WithGrantableTo(roleResourceType),
+				entitlement.WithDescription(fmt.Sprintf("Can %s the %s secret", access, resource.DisplayName)),
+				entitlement.WithDisplayName(fmt.Sprintf("%s secret %s", resource.DisplayName, access)),
+			),
+		)
+	}
+	return entitlements, "", nil, nil
+}
+
+// Grants returns the roles allowed to read or write the secret. The grants
+// are expandable to the members of the role.
+func (o *secretBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	secret, rateLimit, err := o.client.GetSecret(ctx, resource.Id.Resource)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		return nil, "", outputAnnotations, err
+	}
+
+	var secretGrants []*v2.Grant
+	for _, access := range []string{EntitlementRead, EntitlementWrite} {
+		for _, roleId := range secret.RoleIDs(access) {
+			secretGrants = append(
+				secretGrants,
+				grant.NewGrant(
+					resource,
+					access,
+					&v2.ResourceId{
+						ResourceType: roleResourceType.Id,
+						Resource:     roleId,
+					},
+					grant.WithAnnotation(
+						&v2.GrantExpandable{
+							EntitlementIds: []string{roleAssignedEntitlementID(roleId)},
+						},
+					),
+				),
+			)
+		}
+	}
+
+	return secretGrants, "", outputAnnotations, nil
+}
+
+// Grant adds a role to the secret's read or write roles.
+func (o *secretBuilder) Grant(
+	ctx context.Context,
+	principal *v2.Resource,
+	entitlement *v2.Entitlement,
+) (annotations.Annotations, error) {
+	access, err := secretAccess(principal, entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.client.GrantSecretAccess(
+		ctx,
+		entitlement.Resource.Id.Resource,
+		principal.Id.Resource,
+		access,
+	)
+	return nil, err
+}
+
+// Revoke removes a role from the secret's read or write roles.
+func (o *secretBuilder) Revoke(
+	ctx context.Context,
+	grant *v2.Grant,
+) (annotations.Annotations, error) {
+	access, err := secretAccess(grant.Principal, grant.Entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.client.RevokeSecretAccess(
+		ctx,
+		grant.Entitlement.Resource.Id.Resource,
+		grant.Principal.Id.Resource,
+		access,
+	)
+	return nil, err
+}
+
+func newSecretBuilder(client client.PrivXClient) *secretBuilder {
+	return &secretBuilder{client: client}
+}
+
+// secretAccess checks that a secret entitlement is granted to a role and
+// returns the access it stands for.
+func secretAccess(principal *v2.Resource, entitlement *v2.Entitlement) (string, error) {
+	if principal.Id.ResourceType != roleResourceType.Id {
+		return "", status.Error(codes.InvalidArgument, "baton-privx: only roles can be granted access to secrets")
+	}
+
+	switch {
+	case strings.HasSuffix(entitlement.Id, ":"+EntitlementRead):
+		return EntitlementRead, nil
+	case strings.HasSuffix(entitlement.Id, ":"+EntitlementWrite):
+		return EntitlementWrite, nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "baton-privx: unknown secret entitlement %s", entitlement.Id)
+	}
+}
+
+// secretResource Converts a PrivX Vault secret into a ConductorOne Resource.
+// baton has no trait for secrets, so the app trait carries their profile.
+func secretResource(ctx context.Context, secret *client.Secret) (*v2.Resource, error) {
+	createdResource, err := resource.NewAppResource(
+		secret.Name,
+		secretResourceType,
+		secret.Name,
+		[]resource.AppTraitOption{
+			resource.WithAppProfile(
+				map[string]interface{}{
+					"name":       secret.Name,
+					"owner_id":   secret.OwnerID,
+					"author":     secret.Author,
+					"updated_by": secret.UpdatedBy,
+					"created":    secret.Created,
+					"updated":    secret.Updated,
+					"data_keys":  strings.Join(secret.DataKeys, ","),
+				},
+			),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
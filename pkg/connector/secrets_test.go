@@ -0,0 +1,249 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	resourceSdk "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSecretsList(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				writer.WriteHeader(http.StatusOK)
+				json, err := os.ReadFile("./client/fixtures/secrets_page_0.json")
+				require.Nil(t, err)
+				_, err = writer.Write(json)
+				if err != nil {
+					return
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	secretBuilder := newSecretBuilder(*privXClient)
+
+	resources, _, _, err := secretBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+	require.Len(t, resources, 2)
+	require.Equal(t, "db-root", resources[0].Id.Resource)
+
+	appTrait, err := resourceSdk.GetAppTrait(resources[0])
+	require.Nil(t, err)
+	profile := appTrait.Profile.AsMap()
+	require.Equal(t, "password,username", profile["data_keys"])
+	require.NotContains(t, resources[0].String(), "hunter2")
+
+	appTrait, err = resourceSdk.GetAppTrait(resources[1])
+	require.Nil(t, err)
+	require.Equal(t, "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b", appTrait.Profile.AsMap()["owner_id"])
+}
+
+// fakeVault serves a single secret, bumping its version, also sent as its
+// ETag, on every write. Writes only succeed if they match the current ETag.
+// When rotations is set, that many reads of the secret are followed by its
+// value being rotated by someone else. When noETag is set, no ETag is sent.
+type fakeVault struct {
+	t          *testing.T
+	version    int
+	rotations  int
+	noETag     bool
+	value      json.RawMessage
+	readRoles  []rolestore.RoleRef
+	writeRoles []rolestore.RoleRef
+	reads      int
+	puts       int
+}
+
+func (f *fakeVault) secret() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        "db-root",
+		"updated":     strconv.Itoa(f.version),
+		"read_roles":  f.readRoles,
+		"write_roles": f.writeRoles,
+	}
+}
+
+func (f *fakeVault) etag() string {
+	return `"` + strconv.Itoa(f.version) + `"`
+}
+
+func (f *fakeVault) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set(uhttp.ContentType, "application/json")
+	switch {
+	case request.URL.Path == "/vault/api/v1/secrets/db-root" && request.Method == http.MethodPut:
+		if request.Header.Get("If-Match") != f.etag() {
+			writer.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = writer.Write([]byte(`{"error_code": "PRECONDITION_FAILED"}`))
+			return
+		}
+		f.puts++
+		f.version++
+		var update struct {
+			Data       json.RawMessage     `json:"data"`
+			ReadRoles  []rolestore.RoleRef `json:"read_roles"`
+			WriteRoles []rolestore.RoleRef `json:"write_roles"`
+		}
+		require.Nil(f.t, json.NewDecoder(request.Body).Decode(&update))
+		f.value = update.Data
+		f.readRoles = update.ReadRoles
+		f.writeRoles = update.WriteRoles
+	case request.URL.Path == "/vault/api/v1/secrets/db-root":
+		f.reads++
+		if !f.noETag {
+			writer.Header().Set("ETag", f.etag())
+		}
+		secret := f.secret()
+		secret["data"] = f.value
+		_ = json.NewEncoder(writer).Encode(secret)
+		if f.rotations > 0 {
+			f.rotations--
+			f.version++
+			f.value = json.RawMessage(`{"password":"rotated","username":"root"}`)
+		}
+	case request.URL.Path == "/vault/api/v1/metadata/secrets/db-root":
+		_ = json.NewEncoder(writer).Encode(f.secret())
+	default:
+		_, _ = writer.Write([]byte(`{}`))
+	}
+}
+
+func TestSecretsGrantRevoke(t *testing.T) {
+	ctx := context.Background()
+	roleId := "3453395a-2a12-50a5-4fdb-794d567edae0"
+	value := `{"password":"hunter2","username":"root"}`
+	secret := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: secretResourceType.Id, Resource: "db-root"},
+	}
+	role := &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: roleId},
+	}
+
+	newBuilder := func(t *testing.T, fake *fakeVault) *secretBuilder {
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		privXClient, err := client.NewPrivXClient(
+			ctx,
+			server.URL,
+			"apiClientId",
+			"apiClientSecret",
+			"oauthClientId",
+			"oauthClientSecret",
+		)
+		require.Nil(t, err)
+		return newSecretBuilder(*privXClient)
+	}
+
+	t.Run("should grant read access and write the value back as read", func(t *testing.T) {
+		fake := &fakeVault{t: t, value: json.RawMessage(value)}
+		secretBuilder := newBuilder(t, fake)
+
+		_, err := secretBuilder.Grant(ctx, role, &v2.Entitlement{
+			Id:       entitlement.NewEntitlementID(secret, EntitlementRead),
+			Resource: secret,
+		})
+		require.Nil(t, err)
+
+		require.Equal(t, 1, fake.puts)
+		require.Equal(t, []rolestore.RoleRef{{ID: roleId}}, fake.readRoles)
+		require.Empty(t, fake.writeRoles)
+		require.JSONEq(t, value, string(fake.value))
+	})
+
+	t.Run("should not revert a secret rotated before it is written", func(t *testing.T) {
+		fake := &fakeVault{t: t, value: json.RawMessage(value), rotations: 1}
+		secretBuilder := newBuilder(t, fake)
+
+		_, err := secretBuilder.Grant(ctx, role, &v2.Entitlement{
+			Id:       entitlement.NewEntitlementID(secret, EntitlementWrite),
+			Resource: secret,
+		})
+		require.Nil(t, err)
+
+		// The first write is refused, the second one carries the new value.
+		require.Equal(t, 2, fake.reads)
+		require.Equal(t, 1, fake.puts)
+		require.Equal(t, []rolestore.RoleRef{{ID: roleId}}, fake.writeRoles)
+		require.JSONEq(t, `{"password":"rotated","username":"root"}`, string(fake.value))
+	})
+
+	t.Run("should refuse to write a secret without an ETag", func(t *testing.T) {
+		fake := &fakeVault{t: t, value: json.RawMessage(value), noETag: true}
+		secretBuilder := newBuilder(t, fake)
+
+		_, err := secretBuilder.Grant(ctx, role, &v2.Entitlement{
+			Id:       entitlement.NewEntitlementID(secret, EntitlementRead),
+			Resource: secret,
+		})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.Equal(t, 0, fake.puts)
+	})
+
+	t.Run("should revoke write access", func(t *testing.T) {
+		fake := &fakeVault{
+			t:          t,
+			value:      json.RawMessage(value),
+			readRoles:  []rolestore.RoleRef{{ID: roleId}},
+			writeRoles: []rolestore.RoleRef{{ID: roleId}, {ID: "other-role"}},
+		}
+		secretBuilder := newBuilder(t, fake)
+
+		_, err := secretBuilder.Revoke(ctx, &v2.Grant{
+			Entitlement: &v2.Entitlement{
+				Id:       entitlement.NewEntitlementID(secret, EntitlementWrite),
+				Resource: secret,
+			},
+			Principal: role,
+		})
+		require.Nil(t, err)
+
+		require.Equal(t, 1, fake.puts)
+		require.Equal(t, []rolestore.RoleRef{{ID: roleId}}, fake.readRoles)
+		require.Equal(t, []rolestore.RoleRef{{ID: "other-role"}}, fake.writeRoles)
+		require.JSONEq(t, value, string(fake.value))
+	})
+
+	t.Run("should not write a secret the role already has access to", func(t *testing.T) {
+		fake := &fakeVault{
+			t:         t,
+			value:     json.RawMessage(value),
+			readRoles: []rolestore.RoleRef{{ID: roleId}},
+		}
+		secretBuilder := newBuilder(t, fake)
+
+		_, err := secretBuilder.Grant(ctx, role, &v2.Entitlement{
+			Id:       entitlement.NewEntitlementID(secret, EntitlementRead),
+			Resource: secret,
+		})
+		require.Nil(t, err)
+		require.Equal(t, 0, fake.puts)
+	})
+}
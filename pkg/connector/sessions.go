@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/auth"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+type sessionBuilder struct {
+	client client.PrivXClient
+}
+
+func (o *sessionBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return sessionResourceType
+}
+
+// List returns the live PrivX web and API sessions as resource objects. The
+// auth service keeps sessions until they expire, so sessions that were
+// logged out of or have expired are left out.
+func (o *sessionBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	offset, limit, err := parsePageToken(pToken)
+	if err != nil {
+		logger.Error("invalid page token", zap.Error(err))
+	}
+
+	privXSessions, nextToken, rateLimit, err := o.client.GetSessions(ctx, offset, limit)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching sessions", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	now := time.Now()
+	sessionResources := make([]*v2.Resource, 0)
+	for _, session := range privXSessions {
+		if session.LoggedOut || session.Expires.Before(now) {
+			continue
+		}
+
+		sessionCopy := session
+		newResource, err := sessionResource(ctx, &sessionCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		sessionResources = append(sessionResources, newResource)
+	}
+
+	return sessionResources, nextToken, outputAnnotations, nil
+}
+
+// Entitlements always returns an empty slice for sessions.
+func (o *sessionBuilder) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants always returns an empty slice for sessions.
+func (o *sessionBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	pToken *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func newSessionBuilder(client client.PrivXClient) *sessionBuilder {
+	return &sessionBuilder{client: client}
+}
+
+// sessionResource Converts a PrivX Session into a ConductorOne Resource.
+// Sessions are parented by the user they belong to.
+func sessionResource(ctx context.Context, session *auth.Session) (*v2.Resource, error) {
+	createdResource, err := resource.NewAppResource(
+		fmt.Sprintf("%s from %s", session.Username, session.RemoteAddr),
+		sessionResourceType,
+		session.ID,
+		[]resource.AppTraitOption{
+			resource.WithAppProfile(
+				map[string]interface{}{
+					"user_id":       session.UserID,
+					"username":      session.Username,
+					"source_id":     session.SourceID,
+					"domain":        session.Domain,
+					"remote_addr":   session.RemoteAddr,
+					"user_agent":    session.UserAgent,
+					"type":          session.Type,
+					"created":       session.Created.UTC().Format(time.RFC3339),
+					"updated":       session.Updated.UTC().Format(time.RFC3339),
+					"expires":       session.Expires.UTC().Format(time.RFC3339),
+					"token_expires": session.TokenExpires.UTC().Format(time.RFC3339),
+				},
+			),
+		},
+		resource.WithParentResourceID(
+			&v2.ResourceId{
+				ResourceType: userResourceType.Id,
+				Resource:     session.UserID,
+			},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
@@ -0,0 +1,86 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/auth"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionsList(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sessions := []auth.Session{
+		{
+			ID:         "6a1f0d4e-8b2c-4e7a-9d3f-1c5b7e9a2f40",
+			UserID:     "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b",
+			Username:   "marcos",
+			RemoteAddr: "192.0.2.10",
+			UserAgent:  "Mozilla/5.0",
+			Type:       "web",
+			Created:    now.Add(-time.Hour),
+			Expires:    now.Add(time.Hour),
+		},
+		{
+			ID:        "2e9c7b1a-4d6f-4a8e-b0c3-5f7d9e1b3a62",
+			UserID:    "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b",
+			Created:   now.Add(-2 * time.Hour),
+			Expires:   now.Add(time.Hour),
+			LoggedOut: true,
+		},
+		{
+			ID:      "8d3b5f7a-1c9e-4b2d-a6f0-3e5c7a9b1d84",
+			UserID:  "0c7e3f2a-5b1d-4c8e-9f6a-2d4b8e0c1a35",
+			Created: now.Add(-48 * time.Hour),
+			Expires: now.Add(-24 * time.Hour),
+		},
+	}
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				if request.URL.Path != "/auth/api/v1/sessionstorage/sessions/search" {
+					_, _ = writer.Write([]byte(`{}`))
+					return
+				}
+				_ = json.NewEncoder(writer).Encode(map[string]interface{}{"count": len(sessions), "items": sessions})
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	sessionBuilder := newSessionBuilder(*privXClient)
+
+	resources, token, _, err := sessionBuilder.List(ctx, nil, &pagination.Token{})
+	require.Nil(t, err)
+	require.Equal(t, "", token)
+
+	// Logged out and expired sessions are left out.
+	require.Len(t, resources, 1)
+	require.Equal(t, "6a1f0d4e-8b2c-4e7a-9d3f-1c5b7e9a2f40", resources[0].Id.Resource)
+	require.Equal(t, userResourceType.Id, resources[0].ParentResourceId.ResourceType)
+	require.Equal(t, "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b", resources[0].ParentResourceId.Resource)
+
+	appTrait, err := resource.GetAppTrait(resources[0])
+	require.Nil(t, err)
+	require.Equal(t, "192.0.2.10", appTrait.Profile.GetFields()["remote_addr"].GetStringValue())
+	require.Equal(t, "web", appTrait.Profile.GetFields()["type"].GetStringValue())
+}
@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"context"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+type sourceBuilder struct {
+	client client.PrivXClient
+}
+
+func (o *sourceBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return sourceResourceType
+}
+
+// List returns all the directory sources configured in PrivX. Sources are not
+// paginated by the role-store, so the first page contains every source.
+func (o *sourceBuilder) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	pToken *pagination.Token,
+) (
+	[]*v2.Resource,
+	string,
+	annotations.Annotations,
+	error,
+) {
+	logger := ctxzap.Extract(ctx)
+
+	privXSources, rateLimit, err := o.client.GetSources(ctx)
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if err != nil {
+		logger.Debug("Error fetching sources", zap.Error(err))
+		return nil, "", outputAnnotations, err
+	}
+
+	sourceResources := make([]*v2.Resource, 0)
+	for _, source := range privXSources {
+		sourceCopy := source
+		newResource, err := sourceResource(ctx, &sourceCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		sourceResources = append(sourceResources, newResource)
+	}
+
+	return sourceResources, "", outputAnnotations, nil
+}
+
+// Entitlements always returns an empty slice for sources.
+func (o *sourceBuilder) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants always returns an empty slice for sources.
+func (o *sourceBuilder) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	pToken *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func newSourceBuilder(client client.PrivXClient) *sourceBuilder {
+	return &sourceBuilder{client: client}
+}
+
+// sourceResource Converts a PrivX Source into a ConductorOne Resource.
+func sourceResource(ctx context.Context, source *rolestore.Source) (*v2.Resource, error) {
+	createdResource, err := resource.NewGroupResource(
+		source.Name,
+		sourceResourceType,
+		source.ID,
+		[]resource.GroupTraitOption{
+			resource.WithGroupProfile(
+				map[string]interface{}{
+					"name":        source.Name,
+					"type":        source.Connection.Type,
+					"status_code": source.StatusCode,
+					"enabled":     source.Enabled,
+					"ttl":         source.TTL,
+					"tags":        strings.Join(source.Tags, ","),
+				},
+			),
+		},
+		resource.WithDescription(source.Comment),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdResource, nil
+}
@@ -2,6 +2,10 @@ package connector
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/conductorone/baton-privx/pkg/connector/client"
@@ -11,10 +15,24 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type userBuilder struct {
 	client client.PrivXClient
+	// sourcesById are fetched on the first page of each sync and reused for
+	// the following ones.
+	sourcesById map[string]*rolestore.Source
+	// activity is scanned on the first page of each sync and reused for the
+	// following ones.
+	activity *userActivity
+	// apiClientsById are fetched on the first page of each sync and reused
+	// for the following ones.
+	apiClientsById map[string]*client.APIClient
+	// apiClientsSearched are the API clients returned by the user search
+	// during this sync. The others are listed along with the last page.
+	apiClientsSearched map[string]bool
 }
 
 func (o *userBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -44,27 +62,99 @@ func (o *userBuilder) List(
 		logger.Error("invalid page token", zap.Error(err))
 	}
 
-	privXUsers, nextToken, err := o.client.GetUsers(ctx, offset, limit)
+	privXUsers, nextToken, rateLimit, err := o.client.GetUsers(ctx, offset, limit)
 	if err != nil {
 		logger.Debug(
 			"Error fetching users",
 			zap.Error(err),
 		)
-		return nil, "", nil, err
+		return nil, "", rateLimitAnnotations(rateLimit), err
+	}
+
+	// Sources are needed to derive whether each user's directory is enabled.
+	outputAnnotations := rateLimitAnnotations(rateLimit)
+	if pToken.Token == "" || o.sourcesById == nil {
+		privXSources, sourcesRateLimit, err := o.client.GetSources(ctx)
+		if sourcesRateLimit != nil {
+			outputAnnotations = rateLimitAnnotations(sourcesRateLimit)
+		}
+		if err != nil {
+			logger.Debug(
+				"Error fetching sources",
+				zap.Error(err),
+			)
+			return nil, "", outputAnnotations, err
+		}
+		o.sourcesById = make(map[string]*rolestore.Source, len(privXSources))
+		for i := range privXSources {
+			o.sourcesById[privXSources[i].ID] = &privXSources[i]
+		}
+	}
+
+	// API clients show up in the user search with the same IDs, they are
+	// only told apart by looking them up.
+	if pToken.Token == "" || o.apiClientsById == nil {
+		apiClientsById, apiClientsRateLimit, err := getAPIClientsById(ctx, o.client)
+		if apiClientsRateLimit != nil {
+			outputAnnotations = rateLimitAnnotations(apiClientsRateLimit)
+		}
+		if err != nil {
+			return nil, "", outputAnnotations, err
+		}
+		o.apiClientsById = apiClientsById
+		o.apiClientsSearched = make(map[string]bool)
+	}
+
+	if pToken.Token == "" || o.activity == nil {
+		o.activity, err = getUserActivity(ctx, o.client)
+		if err != nil {
+			return nil, "", outputAnnotations, err
+		}
 	}
 
 	userResources := make([]*v2.Resource, 0)
 	for _, user := range privXUsers {
 		userCopy := user
-		newUserResource, err := userResource(ctx, &userCopy)
+		newUserResource, err := userResource(
+			ctx,
+			&userCopy,
+			o.sourcesById[user.Source],
+			o.apiClientsById[user.ID],
+			o.activity,
+		)
 		if err != nil {
 			return nil, "", nil, err
 		}
 
 		userResources = append(userResources, newUserResource)
+		if o.apiClientsById[user.ID] != nil {
+			o.apiClientsSearched[user.ID] = true
+		}
+	}
+
+	// Not every API client is returned by the user search, those missing
+	// from it are synced from the API client listing alone.
+	if nextToken == "" {
+		apiClientIds := make([]string, 0, len(o.apiClientsById))
+		for id := range o.apiClientsById {
+			if !o.apiClientsSearched[id] {
+				apiClientIds = append(apiClientIds, id)
+			}
+		}
+		slices.Sort(apiClientIds)
+
+		for _, id := range apiClientIds {
+			apiClient := o.apiClientsById[id]
+			newUserResource, err := userResource(ctx, apiClientUser(apiClient), nil, apiClient, o.activity)
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			userResources = append(userResources, newUserResource)
+		}
 	}
 
-	return userResources, nextToken, nil, nil
+	return userResources, nextToken, outputAnnotations, nil
 }
 
 // Entitlements always returns an empty slice for users.
@@ -85,26 +175,148 @@ func (o *userBuilder) Grants(
 	return nil, "", nil, nil
 }
 
+// getAPIClientsById returns the PrivX API clients by ID. The API client listing
+// needs more permissions than the user search, so if it is denied none are
+// returned and users are synced without telling API clients apart.
+func getAPIClientsById(
+	ctx context.Context,
+	privXClient client.PrivXClient,
+) (map[string]*client.APIClient, *v2.RateLimitDescription, error) {
+	logger := ctxzap.Extract(ctx)
+
+	privXAPIClients, rateLimit, err := privXClient.GetAPIClients(ctx)
+	if status.Code(err) == codes.PermissionDenied {
+		logger.Warn("baton-privx: not allowed to list API clients, syncing them as regular users", zap.Error(err))
+		return map[string]*client.APIClient{}, rateLimit, nil
+	}
+	if err != nil {
+		logger.Debug("Error fetching API clients", zap.Error(err))
+		return nil, rateLimit, err
+	}
+
+	apiClientsById := make(map[string]*client.APIClient, len(privXAPIClients))
+	for i := range privXAPIClients {
+		apiClientsById[privXAPIClients[i].ID] = &privXAPIClients[i]
+	}
+	return apiClientsById, rateLimit, nil
+}
+
+// userIsAPIClient tells whether a user is an API client. Like
+// getAPIClientsById, it takes users for regular ones if API clients can't be
+// read.
+func userIsAPIClient(ctx context.Context, privXClient client.PrivXClient, userId string) (bool, error) {
+	logger := ctxzap.Extract(ctx)
+
+	isAPIClient, err := privXClient.IsAPIClient(ctx, userId)
+	if status.Code(err) == codes.PermissionDenied {
+		logger.Warn(
+			"baton-privx: not allowed to read API clients, treating the user as a regular one",
+			zap.String("user_id", userId),
+			zap.Error(err),
+		)
+		return false, nil
+	}
+	return isAPIClient, err
+}
+
 func newUserBuilder(client client.PrivXClient) *userBuilder {
 	return &userBuilder{client: client}
 }
 
-// userResource Converts a PrivX User into a ConductorOne Resource.
-func userResource(ctx context.Context, user *rolestore.User) (*v2.Resource, error) {
+// userResource Converts a PrivX User into a ConductorOne Resource. Users are
+// parented by the directory source they were imported from, which may be nil
+// if the source isn't known. Users that are API clients, if apiClient is set,
+// are service accounts. Their last login is the later of their last PrivX
+// login and last host connection, if activity knows of either.
+func userResource(
+	ctx context.Context,
+	user *rolestore.User,
+	source *rolestore.Source,
+	apiClient *client.APIClient,
+	activity *userActivity,
+) (*v2.Resource, error) {
+	var resourceOptions []resource.ResourceOption
+	if user.Source != "" {
+		resourceOptions = append(
+			resourceOptions,
+			resource.WithParentResourceID(
+				&v2.ResourceId{
+					ResourceType: sourceResourceType.Id,
+					Resource:     user.Source,
+				},
+			),
+		)
+	}
+
+	profile := map[string]interface{}{
+		"full_name":          user.FullName,
+		"id":                 user.ID,
+		"principal":          user.Principal,
+		"source":             user.Source,
+		"source_user_id":     user.SourceUserID,
+		"distinguished_name": user.DistinguishedName,
+		"given_name":         user.GivenName,
+		"job_title":          user.Job,
+		"company":            user.Company,
+		"department":         user.Department,
+		"telephone":          user.Telephone,
+		"locale":             user.Locale,
+		"tags":               strings.Join(user.Tags, ","),
+		"created":            user.Created,
+		"updated":            user.Updated,
+		"mfa_status":         user.MFA.Status,
+	}
+	if apiClient != nil {
+		profile["api_client"] = true
+		profile["api_client_name"] = apiClient.Name
+		profile["api_client_author"] = apiClient.Author
+		profile["api_client_created"] = apiClient.Created
+		// PrivX keeps no separate rotation time, regenerating the secret
+		// updates the API client.
+		profile["api_client_updated"] = apiClient.Updated
+		profile["api_client_updated_by"] = apiClient.UpdatedBy
+		profile["api_client_roles"] = strings.Join(apiClientRoleIds(apiClient), ",")
+	}
+	lastLogin := activity.lastLogin(user.ID)
+	if !lastLogin.IsZero() {
+		profile["last_login"] = lastLogin.UTC().Format(time.RFC3339)
+	}
+	lastConnection := activity.lastConnection(user.ID)
+	if !lastConnection.IsZero() {
+		profile["last_connection"] = lastConnection.UTC().Format(time.RFC3339)
+	}
+
+	userTraitOptions := []resource.UserTraitOption{
+		resource.WithUserProfile(profile),
+		resource.WithEmail(user.Email, true),
+		userStatus(user, source),
+	}
+	if user.Principal != "" {
+		userTraitOptions = append(userTraitOptions, resource.WithUserLogin(user.Principal))
+	}
+	created := user.Created
+	if apiClient != nil {
+		userTraitOptions = append(userTraitOptions, resource.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE))
+		if apiClient.Created != "" {
+			created = apiClient.Created
+		}
+	}
+	if createdAt, err := time.Parse(time.RFC3339Nano, created); err == nil {
+		userTraitOptions = append(userTraitOptions, resource.WithCreatedAt(createdAt))
+	}
+	if lastConnection.After(lastLogin) {
+		lastLogin = lastConnection
+	}
+	if !lastLogin.IsZero() {
+		userTraitOptions = append(userTraitOptions, resource.WithLastLogin(lastLogin))
+	}
+
 	createdResource, err := resource.NewUserResource(
 		user.FullName,
 		userResourceType,
 		user.ID,
-		[]resource.UserTraitOption{
-			resource.WithUserProfile(
-				map[string]interface{}{
-					"full_name": user.FullName,
-					"id":        user.ID,
-				},
-			),
-			resource.WithEmail(user.Email, true),
-			resource.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
-		},
+		userTraitOptions,
+		resourceOptions...,
 	)
 	if err != nil {
 		return nil, err
@@ -112,3 +324,44 @@ func userResource(ctx context.Context, user *rolestore.User) (*v2.Resource, erro
 
 	return createdResource, nil
 }
+
+// apiClientUser describes an API client missing from the user search as a
+// user, from what the API client listing tells about it.
+func apiClientUser(apiClient *client.APIClient) *rolestore.User {
+	return &rolestore.User{
+		ID:        apiClient.ID,
+		Principal: apiClient.Name,
+		FullName:  apiClient.Name,
+		Created:   apiClient.Created,
+		Updated:   apiClient.Updated,
+	}
+}
+
+// apiClientRoleIds returns the IDs of the roles held by an API client.
+func apiClientRoleIds(apiClient *client.APIClient) []string {
+	roleIds := make([]string, 0, len(apiClient.Roles))
+	for _, role := range apiClient.Roles {
+		roleIds = append(roleIds, role.ID)
+	}
+	return roleIds
+}
+
+// userStatus derives a user's status from the state of their directory source
+// and whether PrivX has flagged their access token as stale.
+func userStatus(user *rolestore.User, source *rolestore.Source) resource.UserTraitOption {
+	if source != nil && !source.Enabled {
+		return resource.WithDetailedStatus(
+			v2.UserTrait_Status_STATUS_DISABLED,
+			fmt.Sprintf("source %s is disabled", source.Name),
+		)
+	}
+
+	if user.StaleAccessToken {
+		return resource.WithDetailedStatus(
+			v2.UserTrait_Status_STATUS_ENABLED,
+			"access token is stale",
+		)
+	}
+
+	return resource.WithStatus(v2.UserTrait_Status_STATUS_ENABLED)
+}
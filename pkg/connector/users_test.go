@@ -2,13 +2,18 @@ package connector
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/conductorone/baton-privx/pkg/connector/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/stretchr/testify/require"
 )
@@ -51,6 +56,11 @@ func TestUsersList(t *testing.T) {
 		require.Len(t, resources, 3)
 		require.NotEmpty(t, resources[0].Id)
 
+		// Assert users are parented by their directory source.
+		require.NotNil(t, resources[1].ParentResourceId)
+		require.Equal(t, sourceResourceType.Id, resources[1].ParentResourceId.ResourceType)
+		require.Equal(t, "a1ee631f-9698-4a77-8042-2002602f9d08", resources[1].ParentResourceId.Resource)
+
 		require.Equal(t, "", token)
 		require.Len(t, annotations, 0)
 	})
@@ -102,3 +112,254 @@ func TestUsersList(t *testing.T) {
 		require.Len(t, annotations, 0)
 	})
 }
+
+func TestUsersListCachesSources(t *testing.T) {
+	ctx := context.Background()
+	sourceRequests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				if request.URL.Path == "/role-store/api/v1/sources" {
+					sourceRequests++
+				}
+				json, err := os.ReadFile("./client/fixtures/search_page_0.json")
+				require.Nil(t, err)
+				_, _ = writer.Write(json)
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	_, token, _, err := userBuilder.List(ctx, nil, &pagination.Token{Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, "3", token)
+	_, _, _, err = userBuilder.List(ctx, nil, &pagination.Token{Token: token, Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, 1, sourceRequests)
+
+	// A new sync starts from the first page and fetches them again.
+	_, _, _, err = userBuilder.List(ctx, nil, &pagination.Token{Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, 2, sourceRequests)
+}
+
+func TestUsersListCachesActivity(t *testing.T) {
+	ctx := context.Background()
+	sessionSearches := 0
+	connectionSearches := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch request.URL.Path {
+				case "/auth/api/v1/sessionstorage/sessions/search":
+					sessionSearches++
+					_, _ = writer.Write([]byte(`{"count": 1, "items": [
+						{"id": "session-1", "user_id": "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b", "created": "2024-07-09T10:00:00Z"}
+					]}`))
+				case "/connection-manager/api/v1/connections/search":
+					connectionSearches++
+					_, _ = writer.Write([]byte(`{"count": 0, "items": []}`))
+				default:
+					json, err := os.ReadFile("./client/fixtures/search_page_0.json")
+					require.Nil(t, err)
+					_, _ = writer.Write(json)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	_, token, _, err := userBuilder.List(ctx, nil, &pagination.Token{Size: 3})
+	require.Nil(t, err)
+	resources, _, _, err := userBuilder.List(ctx, nil, &pagination.Token{Token: token, Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, 1, sessionSearches)
+	require.Equal(t, 1, connectionSearches)
+
+	// Later pages still get the activity scanned along with the first one.
+	userTrait, err := resource.GetUserTrait(resources[1])
+	require.Nil(t, err)
+	require.Equal(t, "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b", resources[1].Id.Resource)
+	require.Equal(t, time.Date(2024, 7, 9, 10, 0, 0, 0, time.UTC), userTrait.LastLogin.AsTime())
+
+	// A new sync starts from the first page and scans it again.
+	_, _, _, err = userBuilder.List(ctx, nil, &pagination.Token{Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, 2, sessionSearches)
+	require.Equal(t, 2, connectionSearches)
+}
+
+func TestUserResource(t *testing.T) {
+	ctx := context.Background()
+	user := &rolestore.User{
+		ID:        "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b",
+		Principal: "marcos",
+		Source:    "a1ee631f-9698-4a77-8042-2002602f9d08",
+		FullName:  "Marcos Gaeta",
+		Created:   "2024-07-08T16:58:49.468196Z",
+	}
+
+	t.Run("should set login and profile", func(t *testing.T) {
+		userResource, err := userResource(ctx, user, nil, nil, nil)
+		require.Nil(t, err)
+
+		userTrait, err := resource.GetUserTrait(userResource)
+		require.Nil(t, err)
+		require.Equal(t, "marcos", userTrait.Login)
+		require.Equal(t, "marcos", userTrait.Profile.GetFields()["principal"].GetStringValue())
+		require.NotNil(t, userTrait.CreatedAt)
+		require.Equal(t, v2.UserTrait_Status_STATUS_ENABLED, userTrait.Status.Status)
+	})
+
+	t.Run("should be disabled when the source is disabled", func(t *testing.T) {
+		source := &rolestore.Source{
+			ID:      "a1ee631f-9698-4a77-8042-2002602f9d08",
+			Name:    "Local",
+			Enabled: false,
+		}
+		userResource, err := userResource(ctx, user, source, nil, nil)
+		require.Nil(t, err)
+
+		userTrait, err := resource.GetUserTrait(userResource)
+		require.Nil(t, err)
+		require.Equal(t, v2.UserTrait_Status_STATUS_DISABLED, userTrait.Status.Status)
+	})
+	t.Run("should be a service account when it is an API client", func(t *testing.T) {
+		apiClient := &client.APIClient{
+			ID:      user.ID,
+			Name:    "Development",
+			Created: "2024-07-08T17:27:26.178110397Z",
+			Roles: []rolestore.RoleRef{
+				{ID: "3453395a-2a12-50a5-4fdb-794d567edae0"},
+			},
+		}
+		userResource, err := userResource(ctx, user, nil, apiClient, nil)
+		require.Nil(t, err)
+
+		userTrait, err := resource.GetUserTrait(userResource)
+		require.Nil(t, err)
+		require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_SERVICE, userTrait.AccountType)
+		require.Equal(t, "Development", userTrait.Profile.GetFields()["api_client_name"].GetStringValue())
+		require.Equal(
+			t,
+			"3453395a-2a12-50a5-4fdb-794d567edae0",
+			userTrait.Profile.GetFields()["api_client_roles"].GetStringValue(),
+		)
+	})
+
+	t.Run("should set the last login from the latest activity", func(t *testing.T) {
+		activity := &userActivity{
+			lastLogins: map[string]time.Time{
+				user.ID: time.Date(2024, 7, 9, 8, 0, 0, 0, time.UTC),
+			},
+			lastConnections: map[string]time.Time{
+				user.ID: time.Date(2024, 7, 9, 9, 30, 0, 0, time.UTC),
+			},
+		}
+		userResource, err := userResource(ctx, user, nil, nil, activity)
+		require.Nil(t, err)
+
+		userTrait, err := resource.GetUserTrait(userResource)
+		require.Nil(t, err)
+		require.Equal(t, time.Date(2024, 7, 9, 9, 30, 0, 0, time.UTC), userTrait.LastLogin.AsTime())
+		require.Equal(t, "2024-07-09T08:00:00Z", userTrait.Profile.GetFields()["last_login"].GetStringValue())
+		require.Equal(t, "2024-07-09T09:30:00Z", userTrait.Profile.GetFields()["last_connection"].GetStringValue())
+	})
+
+	t.Run("should not set the last login without activity", func(t *testing.T) {
+		userResource, err := userResource(ctx, user, nil, nil, &userActivity{})
+		require.Nil(t, err)
+
+		userTrait, err := resource.GetUserTrait(userResource)
+		require.Nil(t, err)
+		require.Nil(t, userTrait.LastLogin)
+		require.NotContains(t, userTrait.Profile.GetFields(), "last_login")
+	})
+}
+
+func TestUsersListAPIClients(t *testing.T) {
+	ctx := context.Background()
+	apiClientRequests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set(uhttp.ContentType, "application/json")
+				switch {
+				case request.URL.Path == "/local-user-store/api/v1/api-clients":
+					apiClientRequests++
+					_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+						"items": []client.APIClient{
+							{ID: "9ceb1de9-e52f-4a6f-521e-3a0dd2d1537b", Name: "deploy-bot"},
+							{ID: "5a0c9b1e-0d4f-4a8e-9b8e-0b7f6a3c2d1e", Name: "ci-runner"},
+						},
+					})
+				case request.URL.Query().Get("offset") != "":
+					fixture, err := os.ReadFile("./client/fixtures/search_empty.json")
+					require.Nil(t, err)
+					_, _ = writer.Write(fixture)
+				default:
+					fixture, err := os.ReadFile("./client/fixtures/search_page_0.json")
+					require.Nil(t, err)
+					_, _ = writer.Write(fixture)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	privXClient, err := client.NewPrivXClient(
+		ctx,
+		server.URL,
+		"apiClientId",
+		"apiClientSecret",
+		"oauthClientId",
+		"oauthClientSecret",
+	)
+	require.Nil(t, err)
+	userBuilder := newUserBuilder(*privXClient)
+
+	resources, token, _, err := userBuilder.List(ctx, nil, &pagination.Token{Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, "3", token)
+	require.Len(t, resources, 3)
+
+	// The API client missing from the user search is listed with the last
+	// page, the one found by it isn't listed twice.
+	resources, token, _, err = userBuilder.List(ctx, nil, &pagination.Token{Token: token, Size: 3})
+	require.Nil(t, err)
+	require.Equal(t, "", token)
+	require.Len(t, resources, 1)
+	require.Equal(t, "5a0c9b1e-0d4f-4a8e-9b8e-0b7f6a3c2d1e", resources[0].Id.Resource)
+	require.Nil(t, resources[0].ParentResourceId)
+
+	userTrait, err := resource.GetUserTrait(resources[0])
+	require.Nil(t, err)
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_SERVICE, userTrait.AccountType)
+	require.Equal(t, "ci-runner", userTrait.Login)
+
+	require.Equal(t, 1, apiClientRequests)
+}